@@ -0,0 +1,64 @@
+package atom
+
+import "time"
+
+// A Clock tells the time things are timed by, the game is driven by one
+// that only moves when it is stepped so that it runs the same way every
+// time.
+type Clock interface {
+	Now() time.Time
+}
+
+// RealClock is the wall clock.
+type RealClock struct{}
+
+func (RealClock) Now() time.Time {
+	return time.Now()
+}
+
+// StepClock moves forward by Step each time it is advanced and counts
+// the steps taken.
+type StepClock struct {
+	Time  time.Time
+	Step  time.Duration
+	Steps int
+}
+
+func NewStepClock(start time.Time, step time.Duration) *StepClock {
+	return &StepClock{Time: start, Step: step}
+}
+
+func (c *StepClock) Now() time.Time {
+	return c.Time
+}
+
+func (c *StepClock) Advance() {
+	c.Time = c.Time.Add(c.Step)
+	c.Steps++
+}
+
+// Lag keeps a StepClock in step with the wall clock, it tracks how far
+// the steps taken are behind and lets them fall back by at most Max.
+type Lag struct {
+	Last time.Time
+	Lag  time.Duration
+	Max  time.Duration
+}
+
+// Catch adds the wall time since it was last called and returns how many
+// steps of the given size are due.
+func (l *Lag) Catch(now time.Time, step time.Duration) int {
+	l.Lag += now.Sub(l.Last)
+	l.Last = now
+	if l.Lag > l.Max {
+		l.Lag = l.Max
+	}
+	n := int(l.Lag / step)
+	l.Lag -= time.Duration(n) * step
+	return n
+}
+
+// Alpha returns how far along the next step of the given size is.
+func (l *Lag) Alpha(step time.Duration) float64 {
+	return float64(l.Lag) / float64(step)
+}
@@ -0,0 +1,74 @@
+package atom
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStepClock(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		step     time.Duration
+		advances int
+		want     time.Time
+	}{
+		{time.Second / 60, 0, start},
+		{time.Second / 60, 1, start.Add(16666666)},
+		{time.Second / 60, 60, start.Add(60 * 16666666)},
+		{time.Second, 90, start.Add(90 * time.Second)},
+	}
+	for _, tt := range tests {
+		c := NewStepClock(start, tt.step)
+		for i := 0; i < tt.advances; i++ {
+			c.Advance()
+		}
+		if !c.Now().Equal(tt.want) || c.Steps != tt.advances {
+			t.Errorf("%v step advanced %d times: now %v after %d steps, want %v",
+				tt.step, tt.advances, c.Now(), c.Steps, tt.want)
+		}
+	}
+}
+
+func TestLagCatch(t *testing.T) {
+	const step = 10 * time.Millisecond
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		frames []time.Duration
+		steps  []int
+		lag    time.Duration
+	}{
+		{"on time", []time.Duration{10, 10, 10}, []int{1, 1, 1}, 0},
+		{"fast frames", []time.Duration{4, 4, 4, 4}, []int{0, 0, 1, 0}, 6},
+		{"catch up", []time.Duration{10, 35, 10}, []int{1, 3, 1}, 5},
+		{"max lag", []time.Duration{1000, 10}, []int{25, 1}, 0},
+		{"max lag keeps remainder", []time.Duration{5, 1000}, []int{0, 25}, 0},
+		{"no time", []time.Duration{0, 0}, []int{0, 0}, 0},
+	}
+	for _, tt := range tests {
+		clock := NewStepClock(start, step)
+		lag := Lag{Last: start, Max: 250 * time.Millisecond}
+		now := start
+		total := 0
+		for i, d := range tt.frames {
+			now = now.Add(d * time.Millisecond)
+			n := lag.Catch(now, step)
+			if n != tt.steps[i] {
+				t.Errorf("%s: frame %d caught up %d steps, want %d", tt.name, i, n, tt.steps[i])
+			}
+			for ; n > 0; n-- {
+				clock.Advance()
+			}
+			total += tt.steps[i]
+		}
+		if lag.Lag != tt.lag*time.Millisecond {
+			t.Errorf("%s: lag %v, want %v", tt.name, lag.Lag, tt.lag*time.Millisecond)
+		}
+		if clock.Steps != total {
+			t.Errorf("%s: clock took %d steps, want %d", tt.name, clock.Steps, total)
+		}
+		if a := lag.Alpha(step); a < 0 || a >= 1 {
+			t.Errorf("%s: alpha %v out of range", tt.name, a)
+		}
+	}
+}
@@ -1,23 +1,40 @@
 package atom
 
 import (
-	"bufio"
 	"flag"
 	"os"
 	"path/filepath"
 
 	"github.com/qeedquan/go-media/sdl"
+
+	"github.com/qeedquan/go-atomiks/puzzle"
 )
 
+// DefaultSpeed is how many squares per second the cursor and the atoms
+// move.
+const DefaultSpeed = 30
+
 type Config struct {
-	Assets       string
-	Pref         string
-	Fullscreen   bool
-	Sound        bool
-	NoLose       bool
-	Unlocked     bool
-	MaxAuthLevel int
-	Hiscores     [LEVELS]int
+	Assets     string
+	Pref       string
+	Fullscreen bool
+	Sound      bool
+	NoLose     bool
+	Unlocked   bool
+	UndoRefund bool
+	KeepTimer  bool
+	TextLevels bool
+	Backups    int
+	Music      int
+	Effects    int
+	Scale      int
+	Speed      int
+	Keys       map[string]string
+	Pack       *Pack
+	*PackProgress
+	progress Progress
+	flags    map[string]bool
+	saved    Settings
 }
 
 func NewConfig(editor bool) *Config {
@@ -28,57 +45,39 @@ func NewConfig(editor bool) *Config {
 	flag.StringVar(&c.Assets, "assets", c.Assets, "assets directory")
 	flag.StringVar(&c.Pref, "pref", c.Pref, "preference directory")
 	flag.BoolVar(&c.Fullscreen, "fullscreen", false, "fullscreen mode")
-	if !editor {
+	flag.IntVar(&c.Scale, "scale", 2, "window size as a multiple of 320x240")
+	if editor {
+		flag.BoolVar(&c.TextLevels, "text", false, "save levels in the text format")
+		flag.IntVar(&c.Backups, "backups", 3, "number of backups to keep when saving a level")
+	} else {
 		flag.BoolVar(&c.Sound, "sound", true, "enable sound")
+		flag.IntVar(&c.Music, "music", MaxVolume, "music volume from 0 to 128")
+		flag.IntVar(&c.Effects, "effects", MaxVolume, "sound effects volume from 0 to 128")
+		flag.IntVar(&c.Speed, "speed", DefaultSpeed, "animation speed in squares per second, 0 moves at once")
 		flag.BoolVar(&c.NoLose, "no-lose", false, "can't lose")
 		flag.BoolVar(&c.Unlocked, "unlocked", false, "unlock all levels")
+		flag.BoolVar(&c.UndoRefund, "undo-refund", false, "give back move penalty on undo")
+		flag.BoolVar(&c.KeepTimer, "keep-timer", false, "keep the timer running when restarting a level")
 	}
 	flag.Parse()
+	c.loadSettings()
 	c.Load()
+	c.loadAtomKinds()
+	c.SetPack(BuiltinPack(c.Assets))
 	return c
 }
 
-func (c *Config) Save() error {
-	name := filepath.Join(c.Pref, "Atomiks")
-	fd, err := os.Create(name)
-	if err != nil {
-		return err
-	}
-
-	w := bufio.NewWriter(fd)
-	w.WriteByte(byte(c.MaxAuthLevel))
-	for _, s := range c.Hiscores {
-		w.WriteByte(byte(s >> 8))
-		w.WriteByte(byte(s))
+// loadAtomKinds reads what the atom pictures show from the assets, the
+// description built into the puzzle package is used if it isn't there.
+func (c *Config) loadAtomKinds() {
+	err := puzzle.LoadAtomKinds(filepath.Join(c.Assets, puzzle.AtomsFile))
+	if !os.IsNotExist(err) {
+		ek(err)
 	}
-
-	err = w.Flush()
-	xerr := fd.Close()
-	if err == nil {
-		err = xerr
-	}
-
-	return err
 }
 
-func (c *Config) Load() {
-	c.MaxAuthLevel = 1
-	for i := range c.Hiscores {
-		c.Hiscores[i] = 0
-	}
-
-	name := filepath.Join(c.Pref, "Atomiks")
-	fd, err := os.Open(name)
-	if err != nil {
-		return
-	}
-
-	r := bufio.NewReader(fd)
-	c.MaxAuthLevel = readByte(r)
-	for i := range c.Hiscores {
-		c.Hiscores[i] = readShort(r)
-	}
-	if c.MaxAuthLevel < 1 {
-		c.MaxAuthLevel = 1
-	}
+// SetPack switches to a level pack and the progress made in it.
+func (c *Config) SetPack(p *Pack) {
+	c.Pack = p
+	c.PackProgress = c.packProgress(p)
 }
@@ -0,0 +1,87 @@
+package atom
+
+import (
+	"strings"
+	"time"
+
+	"github.com/qeedquan/go-atomiks/puzzle"
+)
+
+// The daily challenge is a pack with a single level generated from the
+// local date, so everyone playing on the same day gets the same board
+// without going online. Its level is named after the date, which keeps
+// the best score and moves of every day in the progress.
+const (
+	DailyID         = "daily"
+	DailyDifficulty = "medium"
+	dateFormat      = "2006-01-02"
+)
+
+// DailyPack returns the daily challenge for the day t falls on.
+func DailyPack(t time.Time) *Pack {
+	return &Pack{
+		Name:   "Daily challenge",
+		Path:   DailyID,
+		ID:     DailyID,
+		Daily:  true,
+		levels: []string{t.Format(dateFormat)},
+	}
+}
+
+// Today reports whether the daily challenge is the one for the day t
+// falls on.
+func (p *Pack) Today(t time.Time) bool {
+	return p.Daily && p.levels[0] == t.Format(dateFormat)
+}
+
+// DailySeed returns the seed the level of a day is generated from.
+func DailySeed(t time.Time) int64 {
+	return int64(t.Year())*10000 + int64(t.Month())*100 + int64(t.Day())
+}
+
+// dailyLevel generates the level of the day once, it is loaded again
+// every time the level is restarted.
+func (p *Pack) dailyLevel() (*puzzle.Level, error) {
+	if p.daily == nil {
+		t, err := time.ParseInLocation(dateFormat, p.levels[0], time.Local)
+		if err != nil {
+			return nil, err
+		}
+		d, _ := puzzle.FindDifficulty(DailyDifficulty)
+		l, err := puzzle.NewGenerator(d, DailySeed(t)).Generate()
+		if err != nil {
+			return nil, err
+		}
+		l.Title = "Daily challenge " + p.levels[0]
+		l.Desc = [2][15]byte{}
+		copy(l.Desc[0][:], "DAILY")
+		copy(l.Desc[1][:], strings.ToUpper(t.Format("Jan 2 2006")))
+		p.daily = l
+	}
+	l := *p.daily
+	return &l, nil
+}
+
+// DailyStreak returns how many days in a row the daily challenge was
+// won, up to the day t falls on. A streak isn't broken until a whole day
+// goes by without a win.
+func (c *Config) DailyStreak(t time.Time) int {
+	pp := c.progress.Packs[DailyID]
+	if pp == nil {
+		return 0
+	}
+	won := func(t time.Time) bool {
+		r := pp.Levels[t.Format(dateFormat)]
+		return r != nil && r.Completed != nil
+	}
+
+	day := t
+	if !won(day) {
+		day = day.AddDate(0, 0, -1)
+	}
+	n := 0
+	for ; won(day); day = day.AddDate(0, 0, -1) {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,61 @@
+package atom
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// backup keeps the last n versions of a file as name.1, the newest, up
+// to name.n before it gets overwritten.
+func backup(name string, n int) error {
+	if n <= 0 {
+		return nil
+	}
+
+	buf, err := ioutil.ReadFile(name)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for i := n - 1; i > 0; i-- {
+		err := os.Rename(fmt.Sprintf("%s.%d", name, i), fmt.Sprintf("%s.%d", name, i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return ioutil.WriteFile(name+".1", buf, 0644)
+}
+
+// writeFile replaces a file by writing to a temporary file next to it
+// and renaming it over, so that the old contents survive a crash.
+func writeFile(name string, data []byte) error {
+	fd, err := ioutil.TempFile(filepath.Dir(name), "."+filepath.Base(name))
+	if err != nil {
+		return err
+	}
+
+	_, err = fd.Write(data)
+	if err == nil {
+		err = fd.Sync()
+	}
+	if err == nil {
+		err = fd.Chmod(0644)
+	}
+	xerr := fd.Close()
+	if err == nil {
+		err = xerr
+	}
+	if err == nil {
+		err = os.Rename(fd.Name(), name)
+	}
+	if err != nil {
+		os.Remove(fd.Name())
+	}
+
+	return err
+}
@@ -1,41 +1,45 @@
 package atom
 
 import (
-	"bufio"
 	"fmt"
 	"image"
+	"image/color"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
-)
 
-const (
-	LEVELS   = 30
-	TILESIZE = 16
+	"github.com/qeedquan/go-atomiks/puzzle"
 )
 
 const (
-	FREE  = 128
-	ATOM  = 64
-	WALL  = 192
-	TYPE  = 192
-	INDEX = 63
+	TILESIZE = 16
 )
 
-type Cursor struct {
-	image.Point
+type Motion struct {
 	Mx, My int
 	Sx, Sy int
-	Ex, Ey int
-	Type   int
-	State  int
 	Moving bool
 }
 
-type Grid struct {
-	Squares [64][64]int
-	Width   int
-	Height  int
+// Step moves the cursor up to speed pixels closer to its square, or all
+// the way there if speed is zero.
+func (m *Motion) Step(speed int) {
+	if speed <= 0 {
+		m.Sx, m.Sy = 0, 0
+	}
+	m.Sx = approach(m.Sx, 0, speed)
+	m.Sy = approach(m.Sy, 0, speed)
+	if m.Sx == 0 && m.Sy == 0 {
+		m.Moving = false
+	}
+}
+
+// Lerp returns where the cursor is drawn from its square when alpha of
+// the time to the next step has gone by.
+func (m *Motion) Lerp(speed int, alpha float64) (int, int) {
+	n := int(float64(speed) * alpha)
+	return approach(m.Sx, 0, n), approach(m.Sy, 0, n)
 }
 
 type Loosetile struct {
@@ -46,50 +50,75 @@ type Loosetile struct {
 	Dx, Dy int
 }
 
-func (g *Grid) Set(x, y, v int) {
-	g.Squares[y][x] = v
-}
-
-func (g *Grid) At(x, y int) int {
-	return g.Squares[y][x]
+// Step moves a sliding atom up to speed pixels closer to where it ends
+// up, or all the way if speed is zero, and reports whether it got there.
+func (l *Loosetile) Step(speed int) bool {
+	if speed <= 0 {
+		l.X, l.Y = l.Ex, l.Ey
+	}
+	l.X = approach(l.X, l.Ex, speed)
+	l.Y = approach(l.Y, l.Ey, speed)
+	return l.X == l.Ex && l.Y == l.Ey
 }
 
-func (g *Grid) Type(x, y int) int {
-	return g.Squares[y][x] & TYPE
+// Lerp returns where a sliding atom is drawn when alpha of the time to
+// the next step has gone by.
+func (l *Loosetile) Lerp(speed int, alpha float64) image.Point {
+	n := int(float64(speed) * alpha)
+	return image.Pt(approach(l.X, l.Ex, n), approach(l.Y, l.Ey, n))
 }
 
-func (g *Grid) Index(x, y int) int {
-	return g.Squares[y][x] & INDEX
+// approach moves x by up to n towards to without going past it.
+func approach(x, to, n int) int {
+	switch {
+	case x < to:
+		if x += n; x > to {
+			x = to
+		}
+	case x > to:
+		if x -= n; x < to {
+			x = to
+		}
+	}
+	return x
 }
 
-func (g *Grid) Tile(gfx *GFX, x, y int) *image.RGBA {
+func (gfx *GFX) Tile(g *puzzle.Grid, x, y int) *image.RGBA {
 	var tile *image.RGBA
-	index := g.At(x, y) & INDEX
+	index := g.Index(x, y)
 	switch g.Type(x, y) {
-	case ATOM:
+	case puzzle.ATOM:
 		tile = gfx.Atom[index]
-	case WALL:
+	case puzzle.WALL:
 		tile = gfx.Wall[index]
-	case FREE:
+	case puzzle.FREE:
 		tile = gfx.Empty
 	}
 	return tile
 }
 
 type Game struct {
+	puzzle.Puzzle
 	conf        *Config
 	screen      *Display
 	gfx         *GFX
+	clock       Clock
 	Editor      bool
-	Cursor      Cursor
-	Field       Grid
-	Solution    Grid
+	Motion      Motion
 	BG          int
 	Desc        [2][15]byte
+	Title       string
+	Author      string
+	Molecule    string
+	Formula     string
+	Blurb       string
+	Size        image.Point
+	View        image.Rectangle
 	Offset      image.Point
 	Level       int
-	Score       int
 	Hiscore     int
+	Hinted      bool
+	Par         int
 	TimeEnd     time.Time
 	Duration    time.Duration
 	PreviewTick time.Time
@@ -97,67 +126,25 @@ type Game struct {
 	Loosing     bool
 	Paused      bool
 	PauseTime   time.Duration
+	Hint        puzzle.Move
+	ShowHint    bool
 }
 
-func NewGame(conf *Config, screen *Display, gfx *GFX, editor bool) *Game {
+func NewGame(conf *Config, screen *Display, gfx *GFX, clock Clock, editor bool) *Game {
 	return &Game{
 		conf:   conf,
 		screen: screen,
 		gfx:    gfx,
+		clock:  clock,
 		Editor: editor,
 	}
 }
 
-func (g *Game) MovedDistance(dir int) int {
-	x := g.Cursor.X
-	y := g.Cursor.Y
-	if g.Field.Type(x, y) != ATOM {
-		return 0
-	}
-
-	i := 0
-	switch dir {
-	case UP:
-		y--
-		for i = y; i >= 0; i-- {
-			if g.Field.Type(x, i) != FREE {
-				break
-			}
-		}
-		return y - i
-	case RIGHT:
-		x++
-		for i = x; i < 16; i++ {
-			if g.Field.Type(i, y) != FREE {
-				break
-			}
-		}
-		return i - x
-	case DOWN:
-		y++
-		for i = y; i < 16; i++ {
-			if g.Field.Type(x, i) != FREE {
-				break
-			}
-		}
-		return i - y
-	case LEFT:
-		x--
-		for i = x; i >= 0; i-- {
-			if g.Field.Type(i, y) != FREE {
-				break
-			}
-		}
-		return x - i
-	}
-
-	return 0
-}
-
 func (g *Game) Load(level int) {
 	defer func() {
 		if g.Editor {
-			g.Offset = image.Pt(32, 32)
+			g.View = image.Rect(32, 32, WIDTH/2, HEIGHT/2-16)
+			g.Offset = g.View.Min
 			g.Cursor.Point = image.ZP
 		}
 	}()
@@ -165,158 +152,182 @@ func (g *Game) Load(level int) {
 		conf:    g.conf,
 		screen:  g.screen,
 		gfx:     g.gfx,
+		clock:   g.clock,
 		Editor:  g.Editor,
+		Size:    image.Pt(puzzle.LevelSize, puzzle.LevelSize),
+		View:    image.Rect(80, 0, WIDTH/2, HEIGHT/2),
 		Offset:  image.Pt(80, 48),
-		TimeEnd: time.Now().Add(60 * time.Second),
+		TimeEnd: g.clock.Now().Add(60 * time.Second),
 		Level:   level,
-		Score:   500,
 	}
+	g.Reset()
 
 	conf := g.conf
-	if level < len(conf.Hiscores) {
-		g.Hiscore = conf.Hiscores[level]
+	if r := conf.Levels[conf.Pack.LevelID(level)]; r != nil {
+		g.Hiscore = r.BestScore
+		g.Hinted = r.Hinted
 	}
 
-	name := filepath.Join(conf.Assets, fmt.Sprintf("lev/lev%04d.dat", level))
-	fd, err := os.Open(name)
+	l, err := conf.Pack.Level(level)
 	if err != nil {
 		return
 	}
-	defer fd.Close()
 
-	r := bufio.NewReader(fd)
-	for y := 0; y < 16; y++ {
-		for x := 0; x < 16; x++ {
-			g.Field.Set(x, y, readByte(r))
-			t := g.Field.Type(x, y)
-			if (t == ATOM || t == FREE) && g.Cursor.Point == image.ZP {
-				g.Cursor.Point = image.Pt(x, y)
-			}
-		}
+	g.Init(l)
+	g.Refund = conf.UndoRefund
+	if conf.NoLose {
+		g.Penalty = 0
 	}
+	g.Duration = time.Duration(l.Duration)
+	g.TimeEnd = g.clock.Now().Add(g.Duration * time.Second)
+	g.PreviewTick = g.clock.Now()
+	g.Desc = l.Desc
+	g.BG = l.BG
+	g.Par = l.Par
+	g.Title = l.Title
+	g.Author = l.Author
+	g.Molecule = l.Molecule
+	g.Formula = l.Formula
+	g.Blurb = l.Blurb
+	g.Size = image.Pt(l.Width, l.Height)
+
+	g.Offset.X = center(g.View.Min.X, g.View.Dx(), g.Field.Width)
+	g.Offset.Y = center(g.View.Min.Y, g.View.Dy(), g.Field.Height)
+	g.Follow(g.Cursor.Point)
+}
 
-	for y := 0; y < 16; y++ {
-		for x := 0; x < 16; x++ {
-			g.Solution.Set(x, y, readByte(r))
-		}
+// center returns where a board n squares across starts to be centered in
+// a view, boards that don't fit start at the edge and scroll.
+// Hold keeps the timer full while the level is played when it can't be
+// lost.
+func (g *Game) Hold() {
+	if g.conf.NoLose {
+		g.TimeEnd = g.clock.Now().Add((g.Duration + 1) * time.Second)
 	}
+}
 
-	g.Duration = time.Duration(readShort(r))
-	g.TimeEnd = time.Now().Add(g.Duration * time.Second)
+// TimedOut reports whether the time for the level ran out.
+func (g *Game) TimedOut() bool {
+	return !g.conf.NoLose && g.clock.Now().After(g.TimeEnd)
+}
 
-	for i := range g.Desc {
-		for x := range g.Desc[i] {
-			g.Desc[i][x] = byte(readByte(r))
+// Tally scores up to n of the seconds left on the timer from tick and
+// returns where it got to and whether it reached the end of the timer.
+func (g *Game) Tally(tick time.Time, n int) (time.Time, bool) {
+	for i := 0; i < n; i++ {
+		g.Score += 10
+		tick = tick.Add(1 * time.Second)
+		if tick.After(g.TimeEnd) {
+			return tick, true
 		}
 	}
+	return tick, false
+}
 
-	g.Cursor.Type = readByte(r)
-	g.BG = readByte(r)
-
-	for y := 0; y < 16; y++ {
-		for x := 0; x < 16; x++ {
-			if t := g.Field.Type(x, y); t == ATOM || t == WALL {
-				if x+1 > g.Field.Width {
-					g.Field.Width = x + 1
-				}
-				if y+1 > g.Field.Height {
-					g.Field.Height = y + 1
-				}
-			}
-		}
+func center(min, size, n int) int {
+	if n*TILESIZE > size {
+		return min
 	}
+	return min + (size-n*TILESIZE)/2
+}
 
-	g.Offset.X += (15 - g.Field.Width) * 8
-	g.Offset.Y = (15 - g.Field.Height) * 8
-
-	for y := 0; y < 16; y++ {
-		for x := 0; x < 16; x++ {
-			if t := g.Solution.Type(x, y); t == ATOM || t == WALL {
-				if x+1 > g.Solution.Width {
-					g.Solution.Width = x + 1
-				}
-				if y+1 > g.Solution.Height {
-					g.Solution.Height = y + 1
-				}
-			}
-		}
+// Follow scrolls the board when it is bigger than the view so that the
+// square at p and the ones around it are shown.
+func (g *Game) Follow(p image.Point) {
+	w, h := g.Field.Width, g.Field.Height
+	if g.Editor {
+		w, h = g.Size.X, g.Size.Y
 	}
+	g.Offset.X = scroll(g.Offset.X, g.View.Min.X, g.View.Max.X, p.X, w)
+	g.Offset.Y = scroll(g.Offset.Y, g.View.Min.Y, g.View.Max.Y, p.Y, h)
 }
 
-func (g *Game) Save(level int) error {
-	conf := g.conf
-	name := filepath.Join(conf.Assets, fmt.Sprintf("lev%04d.dat", level))
-	fd, err := os.Create(name)
-	if err != nil {
-		return err
+func scroll(off, min, max, p, n int) int {
+	k := (max - min) / TILESIZE
+	if n <= k {
+		return off
 	}
 
-	w := bufio.NewWriter(fd)
-	for y := 0; y < 16; y++ {
-		for x := 0; x < 16; x++ {
-			w.WriteByte(byte(g.Field.At(x, y)))
-		}
+	first := (min - off) / TILESIZE
+	switch {
+	case p < first+1:
+		first = p - 1
+	case p > first+k-2:
+		first = p - k + 2
 	}
-
-	for y := 0; y < 16; y++ {
-		for x := 0; x < 16; x++ {
-			w.WriteByte(byte(g.Solution.At(x, y)))
-		}
+	if first > n-k {
+		first = n - k
 	}
+	if first < 0 {
+		first = 0
+	}
+	return min - first*TILESIZE
+}
 
-	w.WriteByte(byte(g.Duration >> 8))
-	w.WriteByte(byte(g.Duration))
-
-	for i := range g.Desc {
-		for x := 0; x < 15; x++ {
-			w.WriteByte(g.Desc[i][x])
-		}
+// LevelData returns the level being played or edited in its file form.
+func (g *Game) LevelData() *puzzle.Level {
+	return &puzzle.Level{
+		Field:    g.Field,
+		Solution: g.Solution,
+		Width:    g.Size.X,
+		Height:   g.Size.Y,
+		Duration: int(g.Duration),
+		Match:    g.Match,
+		Desc:     g.Desc,
+		Cursor:   g.Cursor.Type,
+		BG:       g.BG,
+		Par:      g.Par,
+		Title:    g.Title,
+		Author:   g.Author,
+		Molecule: g.Molecule,
+		Formula:  g.Formula,
+		Blurb:    g.Blurb,
 	}
+}
 
-	w.WriteByte(byte(g.Cursor.Type))
-	w.WriteByte(byte(g.BG))
+// Compound returns the name and formula of the molecule built in the
+// level. The formula is worked out from the atoms of the solution unless
+// the level gives one with the same atoms. Bonus stages have neither.
+func (g *Game) Compound() (name, formula string) {
+	formula = g.Formula
+	if f := g.Solution.Formula(); f != "" && !g.Solution.HasFormula(formula) {
+		formula = f
+	}
+	return strings.ToUpper(g.Molecule), formula
+}
 
-	err = w.Flush()
-	xerr := fd.Close()
-	if err == nil {
-		err = xerr
+// Save saves the level next to the file it was loaded from, keeping
+// backups of the previous versions.
+func (g *Game) Save(level int) error {
+	conf := g.conf
+	name := LevelFile(conf.Assets, level)
+	if conf.TextLevels {
+		name = strings.TrimSuffix(name, filepath.Ext(name)) + puzzle.TextExt
 	}
 
-	return err
+	err := backup(name, conf.Backups)
+	if err != nil {
+		return err
+	}
+	return g.LevelData().Save(name)
 }
 
-func (g *Game) Won() bool {
-	fx, fy := g.Field.Width, g.Field.Height
-	sx, sy := g.Solution.Width, g.Solution.Height
-
-	if fx == 0 || fy == 0 {
-		return false
-	}
-
-	for y := 0; y <= fy-sy; y++ {
-	check:
-		for x := 0; x <= fx-sx; x++ {
-			for yy := 0; yy < sy; yy++ {
-				for xx := 0; xx < sx; xx++ {
-					a := g.Solution.Type(xx, yy)
-					b := g.Field.Type(x+xx, y+yy)
-					if a == ATOM && a != b {
-						continue check
-					}
-				}
-			}
-			return true
-		}
+// LevelFile returns the file a level is stored in, a text level is
+// picked over a binary one if both are there.
+func LevelFile(assets string, level int) string {
+	name := filepath.Join(assets, fmt.Sprintf("lev/lev%04d", level))
+	if _, err := os.Stat(name + puzzle.TextExt); err == nil {
+		return name + puzzle.TextExt
 	}
-	return false
+	return name + ".dat"
 }
 
 func (g *Game) DrawField() {
-	g.drawGrid(&g.Field, 64, 64)
+	g.drawGrid(&g.Field, g.Size.X, g.Size.Y)
 }
 
 func (g *Game) DrawSolution() {
-	g.drawGrid(&g.Solution, 32, 32)
+	g.drawGrid(&g.Solution, g.Size.X, g.Size.Y)
 }
 
 func (g *Game) DrawTile(x, y int, tile *image.RGBA) {
@@ -326,20 +337,30 @@ func (g *Game) DrawTile(x, y int, tile *image.RGBA) {
 	DrawGFX(g.screen, tile, x, y)
 }
 
-func (g *Game) drawGrid(grid *Grid, width, height int) {
+// DrawMark shades the square at x, y of the field.
+func (g *Game) DrawMark(x, y int, c color.RGBA) {
+	x = (g.Offset.X + x*TILESIZE) * 2
+	y = (g.Offset.Y + y*TILESIZE) * 2
+	DrawRect(g.screen, x, y, TILESIZE*2, TILESIZE*2, c.R, c.G, c.B, c.A)
+}
+
+func (g *Game) drawGrid(grid *puzzle.Grid, width, height int) {
 	screen := g.screen
 	gfx := g.gfx
 
 	DrawGFX(screen, gfx.BG[g.BG], 0, 0)
 	for y := 0; y < height; y++ {
 		for x := 0; x < width; x++ {
-			tile := grid.Tile(gfx, x, y)
+			tile := gfx.Tile(grid, x, y)
 			if tile == nil {
 				continue
 			}
 			r := tile.Bounds()
 			xx := g.Offset.X + x*r.Dx()
 			yy := g.Offset.Y + y*r.Dy()
+			if !r.Add(image.Pt(xx, yy)).In(g.View) {
+				continue
+			}
 			DrawGFX(screen, gfx.Empty, xx, yy)
 			DrawGFX(screen, tile, xx, yy)
 		}
@@ -351,59 +372,110 @@ func (g *Game) DrawPreview() {
 	screen := g.screen
 	gfx := g.gfx
 
-	s := &g.Solution
 	DrawGFX(screen, gfx.Info, 0, 0)
 	DrawGFX(screen, gfx.Levsel, 0, 0)
 	if conf.MaxAuthLevel > 1 {
 		DrawGFX(screen, gfx.Levsel2, 0, 0)
 	}
-	for y := 0; y < s.Height; y++ {
-		for x := 0; x < s.Width; x++ {
-			if s.Type(x, y) != ATOM {
-				continue
-			}
-			i := s.Index(x, y)
-			t := gfx.Satom[i]
-			oy := (7 - s.Height) * TILESIZE / 4
-			px := x*TILESIZE/2 + WIDTH/4 - s.Width*TILESIZE/4
-			py := 95 + oy + y*TILESIZE/2
-			DrawGFX(screen, t, px, py)
-		}
-	}
+	g.drawMolecule(WIDTH/4, 95+7*TILESIZE/4, 8, 7)
 
 	r := gfx.Font2[0].Bounds()
-	x := WIDTH/4 - r.Dx()
-	DrawGFX(screen, gfx.Font2[g.Level/10], x, 185)
-	x += r.Dx()
-	DrawGFX(screen, gfx.Font2[g.Level%10], x, 185)
+	digits := fmt.Sprintf("%02d", g.Level)
+	x := WIDTH/4 - len(digits)*r.Dx()/2
+	for _, c := range digits {
+		DrawGFX(screen, gfx.Font2[c-'0'], x, 185)
+		x += r.Dx()
+	}
+
+	left, right := g.PreviewArrows()
+	if g.Level > 1 {
+		drawArrow(screen, left, -1)
+	}
+	if g.Level < conf.Pack.Len() && (g.Level < conf.MaxAuthLevel || conf.Unlocked) {
+		drawArrow(screen, right, 1)
+	}
 
-	if g.Level < conf.MaxAuthLevel || conf.Unlocked {
+	done := g.Level < conf.MaxAuthLevel || conf.Unlocked
+	if conf.Pack.Daily {
+		r := conf.Levels[conf.Pack.LevelID(g.Level)]
+		done = r != nil && r.Completed != nil
+	}
+	if done {
 		r := gfx.Completed.Bounds()
 		DrawGFX(screen, gfx.Completed, 10+WIDTH/4-r.Dx()/2, 110)
 	}
 }
 
+// PreviewArrows returns where the arrows to go to the previous and next
+// level are on the level selection screen.
+func (g *Game) PreviewArrows() (left, right image.Rectangle) {
+	r := g.gfx.Font2[0].Bounds()
+	w := len(fmt.Sprintf("%02d", g.Level)) * r.Dx()
+	left = image.Rect(0, 0, 8, 15).Add(image.Pt(WIDTH/4-w/2-16, 185))
+	right = image.Rect(0, 0, 8, 15).Add(image.Pt(WIDTH/4+w/2+8, 185))
+	return
+}
+
+// drawArrow draws a triangle filling r pointing left or right.
+func drawArrow(screen *Display, r image.Rectangle, dir int) {
+	w, h := r.Dx(), r.Dy()
+	for i := 0; i < w; i++ {
+		n := h * (i + 1) / w
+		x := r.Min.X + i
+		if dir > 0 {
+			x = r.Max.X - 1 - i
+		}
+		y := r.Min.Y + (h-n)/2
+		DrawRect(screen, x*2, y*2, 2, n*2, 0x30, 0x30, 0x80, 255)
+	}
+}
+
 func (g *Game) DrawSmallPreview() {
+	y := 16 + (240 - 71) + 7*TILESIZE/4
+	if g.Desc[1][0] == 0 {
+		y -= 8
+	}
+	g.drawMolecule(4+8*TILESIZE/4, y, 8, 7)
+}
+
+// drawMolecule draws the solution with small atoms centered on x, y,
+// shrinking the atoms if it is more than w by h of them.
+func (g *Game) drawMolecule(x, y, w, h int) {
 	screen := g.screen
 	gfx := g.gfx
 
 	s := &g.Solution
-	for y := 0; y < s.Height; y++ {
-		for x := 0; x < s.Width; x++ {
-			if s.Type(x, y) != ATOM {
+	if s.Width == 0 || s.Height == 0 {
+		return
+	}
+
+	size := TILESIZE / 2
+	if n := w * TILESIZE / 2 / s.Width; n < size {
+		size = n
+	}
+	if n := h * TILESIZE / 2 / s.Height; n < size {
+		size = n
+	}
+	if size < 1 {
+		size = 1
+	}
+
+	x -= s.Width * size / 2
+	y -= s.Height * size / 2
+	for yy := 0; yy < s.Height; yy++ {
+		for xx := 0; xx < s.Width; xx++ {
+			if s.Type(xx, yy) != puzzle.ATOM {
 				continue
 			}
 
-			i := s.Index(x, y)
-			t := gfx.Satom[i]
-			px := ((8 - s.Width) * (TILESIZE / 2)) / 2
-			py := ((7 - s.Height) * (TILESIZE / 2)) / 2
-			if g.Desc[1][0] == 0 {
-				py -= 8
+			t := gfx.Satom[s.Index(xx, yy)]
+			px := x + xx*size
+			py := y + yy*size
+			if size == TILESIZE/2 {
+				DrawGFX(screen, t, px, py)
+			} else {
+				DrawGFXScaled(screen, t, px, py, size, size)
 			}
-			rx := 4 + px + (x * TILESIZE / 2)
-			ry := 16 + (240 - 71) + py + (y * TILESIZE / 2)
-			DrawGFX(screen, t, rx, ry)
 		}
 	}
 }
@@ -0,0 +1,58 @@
+package atom
+
+import (
+	"testing"
+	"time"
+)
+
+// play runs the level for the given number of updates and then tallies
+// the time left the way the won screen does, it returns how many updates
+// the tally took or -1 if the level timed out.
+func play(g *Game, clock *StepClock, updates int) int {
+	for i := 0; i < updates; i++ {
+		g.Hold()
+		if g.TimedOut() {
+			return -1
+		}
+		clock.Advance()
+	}
+
+	tick := clock.Now()
+	for n := 1; n <= 1000; n++ {
+		var done bool
+		if tick, done = g.Tally(tick, 3); done {
+			return n
+		}
+		clock.Advance()
+	}
+	return 0
+}
+
+func TestWonTally(t *testing.T) {
+	tests := []struct {
+		noLose  bool
+		updates int
+		tally   int
+		score   int
+	}{
+		{false, 0, 4, 110},
+		{false, 120, 3, 90},
+		{false, 599, 1, 10},
+		{false, 700, -1, 0},
+		{true, 0, 4, 110},
+		{true, 6000, 4, 110},
+	}
+	for _, tt := range tests {
+		start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+		clock := NewStepClock(start, time.Second/60)
+		g := NewGame(&Config{NoLose: tt.noLose}, nil, nil, clock, false)
+		g.Duration = 10
+		g.TimeEnd = start.Add(g.Duration * time.Second)
+
+		n := play(g, clock, tt.updates)
+		if n != tt.tally || (n > 0 && g.Score != tt.score) {
+			t.Errorf("no lose %v after %d updates: tally took %d updates scoring %d, want %d scoring %d",
+				tt.noLose, tt.updates, n, g.Score, tt.tally, tt.score)
+		}
+	}
+}
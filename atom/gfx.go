@@ -11,6 +11,8 @@ import (
 	"github.com/qeedquan/go-media/sdl/sdlimage/sdlcolor"
 	"github.com/qeedquan/go-media/sdl/sdlmixer"
 	"golang.org/x/image/draw"
+
+	"github.com/qeedquan/go-atomiks/puzzle"
 )
 
 const (
@@ -36,9 +38,9 @@ type GFX struct {
 	Intro        [3]*image.RGBA
 	Levsel       *image.RGBA
 	Levsel2      *image.RGBA
-	Atom         [49]*image.RGBA
-	Satom        [49]*image.RGBA
-	Wall         [19]*image.RGBA
+	Atom         [puzzle.NumAtoms]*image.RGBA
+	Satom        [puzzle.NumAtoms]*image.RGBA
+	Wall         [puzzle.NumWalls]*image.RGBA
 	Explosion    [8]*image.RGBA
 	Empty        *image.RGBA
 	Preview      [2]*image.RGBA
@@ -49,6 +51,7 @@ type GFX struct {
 	Font1        [37]*image.RGBA
 	Font2        [11]*image.RGBA
 	Font3        [26]*image.RGBA
+	Star         [2]*image.RGBA
 }
 
 func NewDisplay(conf *Config, title string, icon bool) *Display {
@@ -69,11 +72,15 @@ func NewDisplay(conf *Config, title string, icon bool) *Display {
 	sdl.SetHint(sdl.HINT_RENDER_SCALE_QUALITY, "best")
 
 	width, height := WIDTH, HEIGHT
+	scale := conf.Scale
+	if scale < 1 {
+		scale = 1
+	}
 	wflag := sdl.WINDOW_RESIZABLE
 	if conf.Fullscreen {
 		wflag |= sdl.WINDOW_FULLSCREEN_DESKTOP
 	}
-	window, renderer, err := sdl.CreateWindowAndRenderer(width, height, wflag)
+	window, renderer, err := sdl.CreateWindowAndRenderer(width/2*scale, height/2*scale, wflag)
 	ck(err)
 
 	texture, err := renderer.CreateTexture(sdl.PIXELFORMAT_ABGR8888, sdl.TEXTUREACCESS_STREAMING, width, height)
@@ -96,6 +103,11 @@ func NewDisplay(conf *Config, title string, icon bool) *Display {
 	return &Display{window, renderer, texture, canvas, conf}
 }
 
+// SetScale resizes the window to a multiple of 320x240.
+func (d *Display) SetScale(scale int) {
+	d.SetSize(WIDTH/2*scale, HEIGHT/2*scale)
+}
+
 func LoadGFX(conf *Config) *GFX {
 	g := &GFX{}
 	g.Title = loadImage(conf, "title.png")
@@ -124,6 +136,7 @@ func LoadGFX(conf *Config) *GFX {
 	loadSheet(conf, "font1.png", 5, 5, g.Font1[:])
 	loadSheet(conf, "font2.png", 14, 16, g.Font2[:])
 	loadSheet(conf, "font3.png", 7, 8, g.Font3[:])
+	loadSheet(conf, "stars.png", 11, 11, g.Star[:])
 
 	return g
 }
@@ -180,6 +193,13 @@ func DrawGFX(dst draw.Image, src image.Image, x, y int) {
 	draw.NearestNeighbor.Scale(dst, dr, src, sr, draw.Over, nil)
 }
 
+// DrawGFXScaled draws src stretched to w by h.
+func DrawGFXScaled(dst draw.Image, src image.Image, x, y, w, h int) {
+	s := 2
+	dr := image.Rect(x*s, y*s, (x+w)*s, (y+h)*s)
+	draw.ApproxBiLinear.Scale(dst, dr, src, src.Bounds(), draw.Over, nil)
+}
+
 func DrawRect(dst draw.Image, x, y, w, h int, r, g, b, a uint8) {
 	c := image.NewUniform(color.RGBA{r, g, b, a})
 	dr := image.Rect(x, y, x+w, y+h)
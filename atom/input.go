@@ -1,55 +1,358 @@
 package atom
 
-import "github.com/qeedquan/go-media/sdl"
+import (
+	"sort"
+	"strings"
+
+	"github.com/qeedquan/go-atomiks/puzzle"
+	"github.com/qeedquan/go-media/sdl"
+)
 
 const (
-	UP = iota + 1
-	RIGHT
-	DOWN
-	LEFT
-	FULLSCREEN
+	UP         = puzzle.UP
+	RIGHT      = puzzle.RIGHT
+	DOWN       = puzzle.DOWN
+	LEFT       = puzzle.LEFT
+	FULLSCREEN = iota + 1
 	HOME
 	END
 	ESC
 	SPACE
 	ENTER
+	HINT
+	UNDO
+	REDO
+	RESTART
+	OPTIONS
+	REPLAY
 	NONE
 	UNKNOWN
 )
 
-func Key(key sdl.Keycode) int {
-	var mod int
+// Actions lists the actions that can be bound, in the order they are
+// shown to the player.
+var Actions = []int{UP, DOWN, LEFT, RIGHT, ENTER, SPACE, ESC, HOME, END, HINT, UNDO, REDO, RESTART, OPTIONS, REPLAY}
+
+var actionNames = map[int]string{
+	UP:      "up",
+	RIGHT:   "right",
+	DOWN:    "down",
+	LEFT:    "left",
+	HOME:    "home",
+	END:     "end",
+	ESC:     "back",
+	SPACE:   "pause",
+	ENTER:   "select",
+	HINT:    "hint",
+	UNDO:    "undo",
+	REDO:    "redo",
+	RESTART: "restart",
+	OPTIONS: "options",
+	REPLAY:  "replay",
+	NONE:    "none",
+}
+
+// ActionName returns the name an action is saved under in the settings.
+func ActionName(action int) string {
+	return actionNames[action]
+}
+
+// ParseAction returns the action with the given name, or UNKNOWN.
+func ParseAction(name string) int {
+	for a, n := range actionNames {
+		if n == name {
+			return a
+		}
+	}
+	return UNKNOWN
+}
+
+// Inputs are named after the SDL key names, game controller buttons are
+// prefixed with "pad:" and the stick directions are the axis name
+// followed by the sign, such as "pad:leftx-".
+const padPrefix = "pad:"
+
+// stickDeadZone is how far a stick has to be pushed to count as a press.
+const stickDeadZone = 16000
+
+// Bindings maps inputs to actions.
+type Bindings map[string]int
+
+// DefaultBindings returns the keys the game has always used along with
+// the usual game controller layout.
+func DefaultBindings() Bindings {
+	b := Bindings{}
+	keys := []struct {
+		key    sdl.Keycode
+		action int
+	}{
+		{sdl.K_LEFT, LEFT},
+		{sdl.K_KP_4, LEFT},
+		{sdl.K_RIGHT, RIGHT},
+		{sdl.K_KP_6, RIGHT},
+		{sdl.K_UP, UP},
+		{sdl.K_KP_8, UP},
+		{sdl.K_DOWN, DOWN},
+		{sdl.K_KP_2, DOWN},
+		{sdl.K_RETURN, ENTER},
+		{sdl.K_KP_5, ENTER},
+		{sdl.K_KP_ENTER, ENTER},
+		{sdl.K_HOME, HOME},
+		{sdl.K_KP_7, HOME},
+		{sdl.K_END, END},
+		{sdl.K_KP_1, END},
+		{sdl.K_ESCAPE, ESC},
+		{sdl.K_SPACE, SPACE},
+		{sdl.K_h, HINT},
+		{sdl.K_u, UNDO},
+		{sdl.K_BACKSPACE, UNDO},
+		{sdl.K_y, REDO},
+		{sdl.K_r, RESTART},
+		{sdl.K_o, OPTIONS},
+		{sdl.K_p, REPLAY},
+	}
+	for _, k := range keys {
+		b[sdl.GetKeyName(k.key)] = k.action
+	}
+
+	buttons := []struct {
+		button sdl.GameControllerButton
+		action int
+	}{
+		{sdl.CONTROLLER_BUTTON_DPAD_UP, UP},
+		{sdl.CONTROLLER_BUTTON_DPAD_DOWN, DOWN},
+		{sdl.CONTROLLER_BUTTON_DPAD_LEFT, LEFT},
+		{sdl.CONTROLLER_BUTTON_DPAD_RIGHT, RIGHT},
+		{sdl.CONTROLLER_BUTTON_A, ENTER},
+		{sdl.CONTROLLER_BUTTON_B, ESC},
+		{sdl.CONTROLLER_BUTTON_X, UNDO},
+		{sdl.CONTROLLER_BUTTON_Y, HINT},
+		{sdl.CONTROLLER_BUTTON_LEFTSHOULDER, UNDO},
+		{sdl.CONTROLLER_BUTTON_RIGHTSHOULDER, REDO},
+		{sdl.CONTROLLER_BUTTON_BACK, RESTART},
+		{sdl.CONTROLLER_BUTTON_START, SPACE},
+		{sdl.CONTROLLER_BUTTON_GUIDE, OPTIONS},
+		{sdl.CONTROLLER_BUTTON_LEFTSTICK, REPLAY},
+	}
+	for _, p := range buttons {
+		b[buttonName(p.button)] = p.action
+	}
+
+	b[axisName(sdl.CONTROLLER_AXIS_LEFTX, -1)] = LEFT
+	b[axisName(sdl.CONTROLLER_AXIS_LEFTX, 1)] = RIGHT
+	b[axisName(sdl.CONTROLLER_AXIS_LEFTY, -1)] = UP
+	b[axisName(sdl.CONTROLLER_AXIS_LEFTY, 1)] = DOWN
+	return b
+}
+
+// Bindings returns the default bindings changed by the ones in the
+// settings. An input bound to "none" does nothing.
+func (c *Config) Bindings() Bindings {
+	b := DefaultBindings()
+	for input, name := range c.Keys {
+		a := ParseAction(name)
+		if a == UNKNOWN {
+			sdl.Log("Unknown action %q for %q", name, input)
+			continue
+		}
+		b[input] = a
+	}
+	return b
+}
+
+// Inputs returns the inputs bound to an action, keys first.
+func (b Bindings) Inputs(action int) []string {
+	var l []string
+	for input, a := range b {
+		if a == action {
+			l = append(l, input)
+		}
+	}
+	sort.Slice(l, func(i, j int) bool {
+		pi, pj := IsPad(l[i]), IsPad(l[j])
+		if pi != pj {
+			return pj
+		}
+		return l[i] < l[j]
+	})
+	return l
+}
+
+// Bind makes input the only one of its kind, key or game controller,
+// to trigger the action. The change is kept in the settings.
+func (c *Config) Bind(input string, action int) {
+	if c.Keys == nil {
+		c.Keys = make(map[string]string)
+	}
+
+	def := DefaultBindings()
+	for _, old := range c.Bindings().Inputs(action) {
+		if IsPad(old) != IsPad(input) {
+			continue
+		}
+		if _, found := def[old]; found {
+			c.Keys[old] = actionNames[NONE]
+		} else {
+			delete(c.Keys, old)
+		}
+	}
+
+	if def[input] == action {
+		delete(c.Keys, input)
+	} else {
+		c.Keys[input] = actionNames[action]
+	}
+}
+
+// ResetBindings goes back to the default bindings.
+func (c *Config) ResetBindings() {
+	c.Keys = nil
+}
+
+// IsPad reports whether the input is on a game controller.
+func IsPad(input string) bool {
+	return strings.HasPrefix(input, padPrefix)
+}
+
+func buttonName(b sdl.GameControllerButton) string {
+	return padPrefix + sdl.GameControllerGetStringForButton(b)
+}
+
+func axisName(a sdl.GameControllerAxis, sign int) string {
+	name := padPrefix + sdl.GameControllerGetStringForAxis(a)
+	if sign < 0 {
+		return name + "-"
+	}
+	return name + "+"
+}
+
+// Input turns keyboard and game controller events into actions. Game
+// controllers are opened as they are plugged in.
+type Input struct {
+	Bindings Bindings
+	pads     map[sdl.JoystickID]*sdl.GameController
+	sticks   map[string]bool
+}
+
+func NewInput(conf *Config) *Input {
+	return &Input{
+		Bindings: conf.Bindings(),
+		pads:     make(map[sdl.JoystickID]*sdl.GameController),
+		sticks:   make(map[string]bool),
+	}
+}
+
+// Event returns the action for an event, NONE if the event isn't an
+// input and UNKNOWN if it is one that isn't bound.
+func (in *Input) Event(ev sdl.Event) int {
+	switch ev := ev.(type) {
+	case sdl.KeyDownEvent:
+		return in.Key(ev.Sym)
+	case sdl.ControllerButtonDownEvent:
+		return in.action(buttonName(sdl.GameControllerButton(ev.Button)))
+	case sdl.ControllerAxisEvent:
+		if name := in.stick(ev); name != "" {
+			return in.action(name)
+		}
+	case sdl.ControllerDeviceAddedEvent:
+		in.addPad(int(ev.Which))
+	case sdl.ControllerDeviceRemovedEvent:
+		in.removePad(sdl.JoystickID(ev.Which))
+	}
+	return NONE
+}
+
+// Name returns the name of the input that sent an event, or an empty
+// string if the event isn't a key or button press. Held down keys
+// repeating don't count.
+func (in *Input) Name(ev sdl.Event) string {
+	switch ev := ev.(type) {
+	case sdl.KeyDownEvent:
+		if ev.Repeat != 0 {
+			return ""
+		}
+		switch ev.Sym {
+		case sdl.K_LALT, sdl.K_RALT:
+			return ""
+		}
+		return sdl.GetKeyName(ev.Sym)
+	case sdl.ControllerButtonDownEvent:
+		return buttonName(sdl.GameControllerButton(ev.Button))
+	case sdl.ControllerAxisEvent:
+		return in.stick(ev)
+	}
+	return ""
+}
+
+// Key returns the action bound to a key, Alt+Enter is always fullscreen
+// and other keys held with Alt do nothing.
+func (in *Input) Key(key sdl.Keycode) int {
 	switch key {
-	case sdl.K_LEFT, sdl.K_KP_4:
-		mod = LEFT
-	case sdl.K_RIGHT, sdl.K_KP_6:
-		mod = RIGHT
-	case sdl.K_UP, sdl.K_KP_8:
-		mod = UP
-	case sdl.K_DOWN, sdl.K_KP_2:
-		mod = DOWN
-	case sdl.K_RETURN, sdl.K_KP_5, sdl.K_KP_ENTER:
-		if sdl.GetModState()&sdl.KMOD_ALT != 0 {
-			mod = FULLSCREEN
-		}
-		mod = ENTER
-	case sdl.K_HOME, sdl.K_KP_7:
-		mod = HOME
-	case sdl.K_END, sdl.K_KP_1:
-		mod = END
-	case sdl.K_ESCAPE:
-		mod = ESC
-	case sdl.K_SPACE:
-		mod = SPACE
 	case sdl.K_LALT, sdl.K_RALT:
-		mod = NONE
-	default:
-		mod = UNKNOWN
+		return NONE
 	}
 
-	if mod != FULLSCREEN && sdl.GetModState()&sdl.KMOD_ALT != 0 {
-		mod = NONE
+	if sdl.GetModState()&sdl.KMOD_ALT != 0 {
+		switch key {
+		case sdl.K_RETURN, sdl.K_KP_ENTER:
+			return FULLSCREEN
+		}
+		return NONE
 	}
+	return in.action(sdl.GetKeyName(key))
+}
+
+func (in *Input) action(name string) int {
+	a, found := in.Bindings[name]
+	if !found {
+		return UNKNOWN
+	}
+	return a
+}
 
-	return mod
+// stick returns the input name when a stick is pushed past the dead
+// zone, it has to come back before it counts again.
+func (in *Input) stick(ev sdl.ControllerAxisEvent) string {
+	axis := sdl.GameControllerAxis(ev.Axis)
+	neg, pos := axisName(axis, -1), axisName(axis, 1)
+	v := int(ev.Value)
+	switch {
+	case v <= -stickDeadZone && !in.sticks[neg]:
+		in.sticks[neg] = true
+		return neg
+	case v >= stickDeadZone && !in.sticks[pos]:
+		in.sticks[pos] = true
+		return pos
+	case -stickDeadZone/2 < v && v < stickDeadZone/2:
+		in.sticks[neg] = false
+		in.sticks[pos] = false
+	}
+	return ""
+}
+
+func (in *Input) addPad(index int) {
+	if !sdl.IsGameController(index) {
+		return
+	}
+	pad, err := sdl.GameControllerOpen(index)
+	if ek(err) {
+		return
+	}
+	id := pad.Joystick().InstanceID()
+	if in.pads[id] != nil {
+		pad.Close()
+		return
+	}
+	in.pads[id] = pad
+	sdl.Log("Game controller connected: %s", pad.Name())
+}
+
+func (in *Input) removePad(id sdl.JoystickID) {
+	pad := in.pads[id]
+	if pad == nil {
+		return
+	}
+	sdl.Log("Game controller disconnected: %s", pad.Name())
+	pad.Close()
+	delete(in.pads, id)
 }
@@ -0,0 +1,271 @@
+package atom
+
+import (
+	"archive/zip"
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/qeedquan/go-atomiks/puzzle"
+)
+
+// A pack is a directory or zip archive of levels with a manifest naming
+// the pack and listing its levels in the order they are played:
+//
+//	name "Chemistry 101"
+//	author "Someone"
+//	level water.txt
+//	level lev0002
+//
+// Levels named without an extension are looked up as text levels first
+// and then as binary ones.
+const PackManifest = "pack.txt"
+
+var ErrEmptyPack = errors.New("pack has no levels")
+
+type Pack struct {
+	Name   string
+	Author string
+	Path   string
+	ID     string
+	Daily  bool
+	levels []string
+	zip    bool
+	root   string
+	daily  *puzzle.Level
+}
+
+// BuiltinPack returns the levels that come with the game, they are
+// numbered files in the level directory of the assets.
+func BuiltinPack(assets string) *Pack {
+	p := &Pack{
+		Name:   "Atomiks",
+		Author: "Mateusz Viste",
+		Path:   filepath.Join(assets, "lev"),
+	}
+	for n := 1; ; n++ {
+		name := LevelFile(assets, n)
+		if _, err := os.Stat(name); err != nil {
+			break
+		}
+		p.levels = append(p.levels, filepath.Base(name))
+	}
+	return p
+}
+
+// OpenPack opens a pack in a directory or zip archive.
+func OpenPack(name string) (*Pack, error) {
+	fi, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Pack{
+		Path: name,
+		ID:   filepath.Base(name),
+		zip:  !fi.IsDir(),
+	}
+	if p.zip {
+		err = p.openZip()
+	} else {
+		var fd *os.File
+		fd, err = os.Open(filepath.Join(name, PackManifest))
+		if err == nil {
+			err = p.readManifest(fd)
+			fd.Close()
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+
+	if p.Name == "" {
+		p.Name = strings.TrimSuffix(p.ID, filepath.Ext(p.ID))
+	}
+	return p, nil
+}
+
+// FindPacks returns the builtin pack followed by the packs found in the
+// packs directory of the assets and of the preferences.
+func FindPacks(conf *Config) []*Pack {
+	packs := []*Pack{BuiltinPack(conf.Assets)}
+	for _, dir := range []string{conf.Assets, conf.Pref} {
+		files, err := ioutil.ReadDir(filepath.Join(dir, "packs"))
+		if err != nil {
+			continue
+		}
+		for _, fi := range files {
+			name := filepath.Join(dir, "packs", fi.Name())
+			if !fi.IsDir() && !strings.EqualFold(filepath.Ext(name), ".zip") {
+				continue
+			}
+			p, err := OpenPack(name)
+			if ek(err) {
+				continue
+			}
+			packs = append(packs, p)
+		}
+	}
+	sort.SliceStable(packs[1:], func(i, j int) bool {
+		return packs[i+1].Name < packs[j+1].Name
+	})
+	return packs
+}
+
+func (p *Pack) Len() int {
+	return len(p.levels)
+}
+
+// LevelID returns the name of level n in the pack without extension,
+// progress is saved under it.
+func (p *Pack) LevelID(n int) string {
+	if n < 1 || n > len(p.levels) {
+		return fmt.Sprintf("lev%04d", n)
+	}
+	name := p.levels[n-1]
+	return strings.TrimSuffix(name, path.Ext(name))
+}
+
+// Level loads level n of the pack, counting from 1.
+func (p *Pack) Level(n int) (*puzzle.Level, error) {
+	if n < 1 || n > len(p.levels) {
+		return nil, fmt.Errorf("%s: no level %d", p.Path, n)
+	}
+	if p.Daily {
+		return p.dailyLevel()
+	}
+	name := p.levels[n-1]
+	if !p.zip {
+		return puzzle.LoadLevel(resolve(filepath.Join(p.Path, name), func(name string) bool {
+			_, err := os.Stat(name)
+			return err == nil
+		}))
+	}
+
+	z, err := zip.OpenReader(p.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer z.Close()
+
+	files := make(map[string]*zip.File)
+	for _, f := range z.File {
+		files[f.Name] = f
+	}
+	name = resolve(path.Join(p.root, name), func(name string) bool {
+		return files[name] != nil
+	})
+	f := files[name]
+	if f == nil {
+		return nil, fmt.Errorf("%s: %s: not found", p.Path, name)
+	}
+
+	r, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var l *puzzle.Level
+	if puzzle.IsText(name) {
+		l, err = puzzle.ReadText(r)
+	} else {
+		l, err = puzzle.ReadLevel(r)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s: %v", p.Path, name, err)
+	}
+	return l, nil
+}
+
+// resolve adds an extension to a level name that has none, preferring
+// a text level if there is one.
+func resolve(name string, exists func(string) bool) string {
+	if filepath.Ext(name) != "" {
+		return name
+	}
+	if exists(name + puzzle.TextExt) {
+		return name + puzzle.TextExt
+	}
+	return name + ".dat"
+}
+
+// openZip reads the manifest of a zip archive, the levels are relative
+// to the directory the manifest is in.
+func (p *Pack) openZip() error {
+	z, err := zip.OpenReader(p.Path)
+	if err != nil {
+		return err
+	}
+	defer z.Close()
+
+	var manifest *zip.File
+	for _, f := range z.File {
+		if path.Base(f.Name) != PackManifest {
+			continue
+		}
+		if manifest == nil || len(f.Name) < len(manifest.Name) {
+			manifest = f
+		}
+	}
+	if manifest == nil {
+		return fmt.Errorf("no %s", PackManifest)
+	}
+	p.root = path.Dir(manifest.Name)
+
+	r, err := manifest.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return p.readManifest(r)
+}
+
+func (p *Pack) readManifest(r io.Reader) error {
+	s := bufio.NewScanner(r)
+	for line := 1; s.Scan(); line++ {
+		text := strings.TrimSpace(s.Text())
+		key, value := text, ""
+		if n := strings.IndexByte(text, ' '); n >= 0 {
+			key, value = text[:n], strings.TrimSpace(text[n+1:])
+		}
+
+		var err error
+		switch key {
+		case "name":
+			p.Name, err = unquote(value)
+		case "author":
+			p.Author, err = unquote(value)
+		case "level":
+			var name string
+			name, err = unquote(value)
+			p.levels = append(p.levels, name)
+		}
+		if err != nil {
+			return fmt.Errorf("%s:%d: %v", PackManifest, line, err)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return err
+	}
+	if len(p.levels) == 0 {
+		return ErrEmptyPack
+	}
+	return nil
+}
+
+// unquote allows manifest values to be written with or without quotes.
+func unquote(s string) (string, error) {
+	if strings.HasPrefix(s, `"`) {
+		return strconv.Unquote(s)
+	}
+	return s, nil
+}
@@ -0,0 +1,134 @@
+package atom
+
+import (
+	"image"
+
+	"github.com/qeedquan/go-media/sdl"
+)
+
+// Kinds of pointer events.
+const (
+	PRESS = iota + 1
+	DRAG
+	RELEASE
+)
+
+// Pointer turns mouse and touch events into presses, drags and releases
+// in the 320x240 coordinates everything is drawn in. Only one button or
+// finger is followed at a time.
+type Pointer struct {
+	Down   bool
+	Start  image.Point
+	Pos    image.Point
+	finger sdl.FingerID
+	touch  bool
+	screen *Display
+}
+
+func NewPointer(screen *Display) *Pointer {
+	return &Pointer{screen: screen}
+}
+
+// Event returns the kind of pointer event and where it happened, or zero
+// if the event isn't one. Mouse events that SDL makes up from touches
+// are skipped since the touches are handled directly. The mouse cursor
+// is hidden until the mouse moves.
+func (p *Pointer) Event(ev sdl.Event) (int, image.Point) {
+	switch ev := ev.(type) {
+	case sdl.MouseButtonDownEvent:
+		if ev.Which == sdl.TOUCH_MOUSEID || ev.Button != sdl.BUTTON_LEFT || p.Down {
+			break
+		}
+		p.touch = false
+		return p.press(image.Pt(int(ev.X), int(ev.Y)).Div(2))
+	case sdl.MouseMotionEvent:
+		if ev.Which == sdl.TOUCH_MOUSEID {
+			break
+		}
+		sdl.ShowCursor(sdl.ENABLE)
+		if !p.Down || p.touch {
+			break
+		}
+		return p.drag(image.Pt(int(ev.X), int(ev.Y)).Div(2))
+	case sdl.MouseButtonUpEvent:
+		if ev.Which == sdl.TOUCH_MOUSEID || ev.Button != sdl.BUTTON_LEFT || !p.Down || p.touch {
+			break
+		}
+		return p.release(image.Pt(int(ev.X), int(ev.Y)).Div(2))
+	case sdl.FingerDownEvent:
+		if p.Down {
+			break
+		}
+		p.touch = true
+		p.finger = ev.FingerID
+		return p.press(p.screen.touchPoint(ev.X, ev.Y))
+	case sdl.FingerMotionEvent:
+		if !p.Down || !p.touch || ev.FingerID != p.finger {
+			break
+		}
+		return p.drag(p.screen.touchPoint(ev.X, ev.Y))
+	case sdl.FingerUpEvent:
+		if !p.Down || !p.touch || ev.FingerID != p.finger {
+			break
+		}
+		return p.release(p.screen.touchPoint(ev.X, ev.Y))
+	}
+	return 0, image.ZP
+}
+
+// Moved returns how far the pointer went since it was pressed.
+func (p *Pointer) Moved() image.Point {
+	return p.Pos.Sub(p.Start)
+}
+
+func (p *Pointer) press(pt image.Point) (int, image.Point) {
+	p.Down = true
+	p.Start = pt
+	p.Pos = pt
+	return PRESS, pt
+}
+
+func (p *Pointer) drag(pt image.Point) (int, image.Point) {
+	p.Pos = pt
+	return DRAG, pt
+}
+
+func (p *Pointer) release(pt image.Point) (int, image.Point) {
+	p.Down = false
+	p.Pos = pt
+	return RELEASE, pt
+}
+
+// touchPoint converts a touch position, which goes from 0 to 1 across
+// the window, taking into account the bars the renderer adds to keep the
+// aspect ratio.
+func (d *Display) touchPoint(x, y float32) image.Point {
+	w, h := d.Window.GetSize()
+	scale := float32(w) / WIDTH
+	if s := float32(h) / HEIGHT; s < scale {
+		scale = s
+	}
+	if scale <= 0 {
+		return image.ZP
+	}
+	bx := (float32(w) - WIDTH*scale) / 2
+	by := (float32(h) - HEIGHT*scale) / 2
+	return image.Pt(int((x*float32(w)-bx)/scale/2), int((y*float32(h)-by)/scale/2))
+}
+
+// Square returns the board square at a point on the screen, it has to be
+// in view.
+func (g *Game) Square(pt image.Point) (image.Point, bool) {
+	if !pt.In(g.View) {
+		return image.ZP, false
+	}
+	pt = pt.Sub(g.Offset)
+	if pt.X < 0 || pt.Y < 0 {
+		return image.ZP, false
+	}
+	sq := pt.Div(TILESIZE)
+	if sq.X >= g.Size.X || sq.Y >= g.Size.Y {
+		return image.ZP, false
+	}
+	return sq, true
+}
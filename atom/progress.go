@@ -0,0 +1,169 @@
+package atom
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const ProgressVersion = 1
+
+// Progress is what the player achieved in every pack, keyed by the pack
+// id and then by the level id. It is saved as JSON in the preference
+// directory.
+type Progress struct {
+	Version int                      `json:"version"`
+	Packs   map[string]*PackProgress `json:"packs"`
+}
+
+type PackProgress struct {
+	MaxAuthLevel int                     `json:"max_level"`
+	Levels       map[string]*LevelRecord `json:"levels,omitempty"`
+}
+
+// LevelRecord holds the best results on a level, the best time is in
+// seconds.
+type LevelRecord struct {
+	BestScore   int        `json:"best_score,omitempty"`
+	BestTime    int        `json:"best_time,omitempty"`
+	FewestMoves int        `json:"fewest_moves,omitempty"`
+	Completed   *time.Time `json:"completed,omitempty"`
+	Attempts    int        `json:"attempts,omitempty"`
+	Restarts    int        `json:"restarts,omitempty"`
+	Hinted      bool       `json:"hinted,omitempty"`
+}
+
+// Win records a win with the given score, time and number of moves,
+// keeping the best of each. The hint flag goes with the best score.
+func (r *LevelRecord) Win(score int, taken time.Duration, moves int, hinted bool, now time.Time) {
+	if r.Completed == nil {
+		r.Completed = &now
+	}
+	if score >= r.BestScore {
+		r.BestScore = score
+		r.Hinted = hinted
+	}
+	if secs := int(taken.Seconds() + 0.5); r.BestTime == 0 || secs < r.BestTime {
+		r.BestTime = secs
+	}
+	if r.FewestMoves == 0 || moves < r.FewestMoves {
+		r.FewestMoves = moves
+	}
+}
+
+func (c *Config) progressFile() string {
+	return filepath.Join(c.Pref, "progress.json")
+}
+
+// legacyProgressFile returns where the original game saved the progress
+// in the pack.
+func (c *Config) legacyProgressFile(p *Pack) string {
+	name := "Atomiks"
+	if p.ID != "" {
+		name += "-" + p.ID
+	}
+	return filepath.Join(c.Pref, name)
+}
+
+func (c *Config) Save() error {
+	c.progress.Version = ProgressVersion
+	buf, err := json.MarshalIndent(c.progress, "", "\t")
+	if err != nil {
+		return err
+	}
+	return writeFile(c.progressFile(), buf)
+}
+
+func (c *Config) Load() {
+	c.progress = Progress{}
+	fd, err := os.Open(c.progressFile())
+	if err == nil {
+		err = json.NewDecoder(fd).Decode(&c.progress)
+		fd.Close()
+		ek(err)
+	}
+	if c.progress.Packs == nil {
+		c.progress.Packs = make(map[string]*PackProgress)
+	}
+}
+
+func packID(p *Pack) string {
+	if p.ID == "" {
+		return "atomiks"
+	}
+	return p.ID
+}
+
+// packProgress returns the progress in a pack, migrating it from the
+// legacy save file the first time the pack is played.
+func (c *Config) packProgress(p *Pack) *PackProgress {
+	id := packID(p)
+	pp := c.progress.Packs[id]
+	if pp == nil {
+		pp = &PackProgress{MaxAuthLevel: 1}
+		c.loadLegacy(p, pp)
+		c.progress.Packs[id] = pp
+	}
+	if pp.Levels == nil {
+		pp.Levels = make(map[string]*LevelRecord)
+	}
+	if pp.MaxAuthLevel < 1 {
+		pp.MaxAuthLevel = 1
+	}
+	return pp
+}
+
+// loadLegacy reads a save file of the original game, which has the
+// highest level reached followed by the hiscores, and the hint flags and
+// restart counts added later.
+func (c *Config) loadLegacy(p *Pack, pp *PackProgress) {
+	fd, err := os.Open(c.legacyProgressFile(p))
+	if err != nil {
+		return
+	}
+	defer fd.Close()
+
+	n := p.Len()
+	r := bufio.NewReader(fd)
+	records := make([]LevelRecord, n)
+	pp.MaxAuthLevel = readByte(r)
+	for i := range records {
+		records[i].BestScore = readShort(r)
+	}
+	for i := range records {
+		records[i].Hinted = readByte(r) != 0
+	}
+	for i := range records {
+		records[i].Restarts = readShort(r)
+	}
+
+	pp.Levels = make(map[string]*LevelRecord)
+	for i := range records {
+		if records[i] != (LevelRecord{}) {
+			pp.Levels[p.LevelID(i+1)] = &records[i]
+		}
+	}
+}
+
+// ResetProgress forgets everything done in the current pack.
+func (c *Config) ResetProgress() {
+	c.PackProgress = &PackProgress{
+		MaxAuthLevel: 1,
+		Levels:       make(map[string]*LevelRecord),
+	}
+	c.progress.Packs[packID(c.Pack)] = c.PackProgress
+}
+
+// Record returns the record for a level of the current pack, counting
+// from 1.
+func (c *Config) Record(level int) *LevelRecord {
+	id := c.Pack.LevelID(level)
+	r := c.Levels[id]
+	if r == nil {
+		r = &LevelRecord{}
+		c.Levels[id] = r
+	}
+	return r
+}
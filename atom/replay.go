@@ -0,0 +1,97 @@
+package atom
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/qeedquan/go-atomiks/puzzle"
+)
+
+const ReplayVersion = 2
+
+// A Replay is what the player did on a level, timed in updates from
+// when the level was started. It has the options that change the rules
+// and the result so that a replay that doesn't come out the same can be
+// told apart.
+type Replay struct {
+	Version    int           `json:"version"`
+	Pack       string        `json:"pack"`
+	Level      string        `json:"level"`
+	Date       time.Time     `json:"date"`
+	Rate       int           `json:"rate"`
+	Score      int           `json:"score"`
+	Moves      int           `json:"moves"`
+	Time       int           `json:"time"`
+	Speed      int           `json:"speed"`
+	UndoRefund bool          `json:"undo_refund,omitempty"`
+	KeepTimer  bool          `json:"keep_timer,omitempty"`
+	NoLose     bool          `json:"no_lose,omitempty"`
+	Events     []ReplayEvent `json:"events"`
+}
+
+// A ReplayEvent is an action from the keyboard or game controller, a
+// square picked or slide made with the pointer, or a hint showing up.
+type ReplayEvent struct {
+	Frame  int          `json:"frame"`
+	Action string       `json:"action"`
+	At     *image.Point `json:"at,omitempty"`
+	Dir    int          `json:"dir,omitempty"`
+	Hint   *puzzle.Move `json:"hint,omitempty"`
+}
+
+// Replay event actions other than the action names.
+const (
+	ReplayPick  = "pick"
+	ReplaySlide = "slide"
+	ReplayHint  = "hint"
+)
+
+// ReplayFile returns where the replay of a level of the current pack is
+// kept.
+func (c *Config) ReplayFile(level int) string {
+	return filepath.Join(c.Pref, "replays", packID(c.Pack), c.Pack.LevelID(level)+".json")
+}
+
+func (c *Config) HasReplay(level int) bool {
+	_, err := os.Stat(c.ReplayFile(level))
+	return err == nil
+}
+
+func (c *Config) SaveReplay(level int, r *Replay) error {
+	r.Version = ReplayVersion
+	r.Pack = packID(c.Pack)
+	r.Level = c.Pack.LevelID(level)
+
+	name := c.ReplayFile(level)
+	err := os.MkdirAll(filepath.Dir(name), 0755)
+	if err != nil {
+		return err
+	}
+	buf, err := json.MarshalIndent(r, "", "\t")
+	if err != nil {
+		return err
+	}
+	return writeFile(name, buf)
+}
+
+func (c *Config) LoadReplay(level int) (*Replay, error) {
+	fd, err := os.Open(c.ReplayFile(level))
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
+	r := &Replay{}
+	err = json.NewDecoder(fd).Decode(r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", fd.Name(), err)
+	}
+	if r.Version < 2 {
+		r.Speed = DefaultSpeed
+	}
+	return r, nil
+}
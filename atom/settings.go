@@ -0,0 +1,161 @@
+package atom
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+)
+
+// Settings are the options kept in the preference directory between
+// runs, options given on the command line take precedence over them.
+// The assets directory is only ever read from the file.
+type Settings struct {
+	Assets     string            `json:"assets,omitempty"`
+	Fullscreen bool              `json:"fullscreen"`
+	Sound      bool              `json:"sound"`
+	Music      int               `json:"music"`
+	Effects    int               `json:"effects"`
+	Scale      int               `json:"scale"`
+	Speed      int               `json:"speed"`
+	NoLose     bool              `json:"no_lose"`
+	Unlocked   bool              `json:"unlocked"`
+	UndoRefund bool              `json:"undo_refund"`
+	KeepTimer  bool              `json:"keep_timer"`
+	Keys       map[string]string `json:"keys,omitempty"`
+}
+
+func (c *Config) settingsFile() string {
+	return filepath.Join(c.Pref, "settings.json")
+}
+
+// loadSettings applies the saved settings that were not given on the
+// command line.
+func (c *Config) loadSettings() {
+	c.flags = make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		c.flags[f.Name] = true
+	})
+
+	c.saved = c.Settings()
+	c.saved.Assets = ""
+	fd, err := os.Open(c.settingsFile())
+	if err != nil {
+		return
+	}
+	err = json.NewDecoder(fd).Decode(&c.saved)
+	fd.Close()
+	if ek(err) {
+		return
+	}
+
+	s := &c.saved
+	if !c.flags["assets"] && s.Assets != "" {
+		c.Assets = s.Assets
+	}
+	if !c.flags["fullscreen"] {
+		c.Fullscreen = s.Fullscreen
+	}
+	if !c.flags["sound"] {
+		c.Sound = s.Sound
+	}
+	if !c.flags["music"] {
+		c.Music = s.Music
+	}
+	if !c.flags["effects"] {
+		c.Effects = s.Effects
+	}
+	if !c.flags["scale"] {
+		c.Scale = s.Scale
+	}
+	if !c.flags["speed"] {
+		c.Speed = s.Speed
+	}
+	if !c.flags["no-lose"] {
+		c.NoLose = s.NoLose
+	}
+	if !c.flags["unlocked"] {
+		c.Unlocked = s.Unlocked
+	}
+	if !c.flags["undo-refund"] {
+		c.UndoRefund = s.UndoRefund
+	}
+	if !c.flags["keep-timer"] {
+		c.KeepTimer = s.KeepTimer
+	}
+	if s.Keys != nil {
+		c.Keys = s.Keys
+	}
+}
+
+// Settings returns the current settings.
+func (c *Config) Settings() Settings {
+	return Settings{
+		Assets:     c.Assets,
+		Fullscreen: c.Fullscreen,
+		Sound:      c.Sound,
+		Music:      c.Music,
+		Effects:    c.Effects,
+		Scale:      c.Scale,
+		Speed:      c.Speed,
+		NoLose:     c.NoLose,
+		Unlocked:   c.Unlocked,
+		UndoRefund: c.UndoRefund,
+		KeepTimer:  c.KeepTimer,
+		Keys:       c.Keys,
+	}
+}
+
+// SaveSettings writes the settings back. Options that were given on
+// the command line keep their saved value so that they only last for
+// the run, unless they were changed with Set since.
+func (c *Config) SaveSettings() error {
+	s := c.Settings()
+	s.Assets = c.saved.Assets
+	if c.flags["fullscreen"] {
+		s.Fullscreen = c.saved.Fullscreen
+	}
+	if c.flags["sound"] {
+		s.Sound = c.saved.Sound
+	}
+	if c.flags["music"] {
+		s.Music = c.saved.Music
+	}
+	if c.flags["effects"] {
+		s.Effects = c.saved.Effects
+	}
+	if c.flags["scale"] {
+		s.Scale = c.saved.Scale
+	}
+	if c.flags["speed"] {
+		s.Speed = c.saved.Speed
+	}
+	if c.flags["no-lose"] {
+		s.NoLose = c.saved.NoLose
+	}
+	if c.flags["unlocked"] {
+		s.Unlocked = c.saved.Unlocked
+	}
+	if c.flags["undo-refund"] {
+		s.UndoRefund = c.saved.UndoRefund
+	}
+	if c.flags["keep-timer"] {
+		s.KeepTimer = c.saved.KeepTimer
+	}
+
+	buf, err := json.MarshalIndent(s, "", "\t")
+	if err != nil {
+		return err
+	}
+	err = writeFile(c.settingsFile(), buf)
+	if err == nil {
+		c.saved = s
+	}
+	return err
+}
+
+// Set marks an option given on the command line as changed in the game,
+// so that SaveSettings keeps the new value.
+func (c *Config) Set(name string) {
+	delete(c.flags, name)
+}
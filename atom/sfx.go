@@ -15,8 +15,10 @@ type SFX struct {
 	Selected *sdlmixer.Chunk
 }
 
+const MaxVolume = 128
+
 func LoadSFX(conf *Config) *SFX {
-	return &SFX{
+	sfx := &SFX{
 		conf:     conf,
 		Title:    loadMusic(conf, "title.ogg"),
 		End:      loadMusic(conf, "end.ogg"),
@@ -24,6 +26,34 @@ func LoadSFX(conf *Config) *SFX {
 		Explode:  loadSound(conf, "explode.wav"),
 		Selected: loadSound(conf, "selected.wav"),
 	}
+	sfx.SetMusicVolume(conf.Music)
+	sfx.SetEffectsVolume(conf.Effects)
+	return sfx
+}
+
+// SetMusicVolume sets the volume of the music, from 0 to MaxVolume.
+func (sfx *SFX) SetMusicVolume(volume int) {
+	volume = clampVolume(volume)
+	sfx.conf.Music = volume
+	sdlmixer.VolumeMusic(volume)
+}
+
+// SetEffectsVolume sets the volume of the sound effects, from 0 to
+// MaxVolume.
+func (sfx *SFX) SetEffectsVolume(volume int) {
+	volume = clampVolume(volume)
+	sfx.conf.Effects = volume
+	sdlmixer.Volume(-1, volume)
+}
+
+func clampVolume(volume int) int {
+	if volume < 0 {
+		return 0
+	}
+	if volume > MaxVolume {
+		return MaxVolume
+	}
+	return volume
 }
 
 func loadMusic(conf *Config, name string) *sdlmixer.Music {
@@ -13,6 +13,7 @@ type Slide struct {
 
 type Slideshow struct {
 	screen  *Display
+	clock   Clock
 	advance bool
 	events  bool
 	Quit    bool
@@ -22,12 +23,13 @@ type Slideshow struct {
 	Frame   *image.RGBA
 }
 
-func (s *Slideshow) Init(screen *Display, slides []Slide, events bool) {
+func (s *Slideshow) Init(screen *Display, clock Clock, slides []Slide, events bool) {
 	*s = Slideshow{
 		screen: screen,
+		clock:  clock,
 		events: events,
 		Slides: slides,
-		Start:  time.Now(),
+		Start:  clock.Now(),
 		Frame:  image.NewRGBA(image.Rect(0, 0, WIDTH, HEIGHT)),
 	}
 }
@@ -53,7 +55,7 @@ func (s *Slideshow) Update() bool {
 	d := s.Slides[s.Index].Duration
 	if d > 0 {
 		t := s.Start.Add(d)
-		n := time.Now()
+		n := s.clock.Now()
 		if n.After(t) {
 			s.advance = true
 		}
@@ -64,7 +66,7 @@ func (s *Slideshow) Update() bool {
 		if s.Index < len(s.Slides) {
 			s.Index++
 		}
-		s.Start = time.Now()
+		s.Start = s.clock.Now()
 	}
 
 	return false
@@ -7,6 +7,7 @@ import (
 	"math/rand"
 	"os"
 	"runtime"
+	"strings"
 	"time"
 
 	"github.com/qeedquan/go-media/sdl"
@@ -14,23 +15,41 @@ import (
 	"github.com/qeedquan/go-media/sdl/sdlmixer"
 
 	"github.com/qeedquan/go-atomiks/atom"
+	"github.com/qeedquan/go-atomiks/puzzle"
 )
 
+type parResult struct {
+	pack  *atom.Pack
+	level int
+	par   int
+}
+
+type hintResult struct {
+	serial int
+	moves  int
+	move   puzzle.Move
+	err    error
+}
+
 const (
 	INTRO = iota + 1
+	PACKS
 	SELECT
 	PLAY
 	WON
 	TIMEOUT
 	CREDITS
+	OPTIONS
 	EXIT
 )
 
 var (
-	conf   *atom.Config
-	screen *atom.Display
-	gfx    *atom.GFX
-	sfx    *atom.SFX
+	conf    *atom.Config
+	screen  *atom.Display
+	gfx     *atom.GFX
+	sfx     *atom.SFX
+	input   *atom.Input
+	pointer *atom.Pointer
 
 	game    *atom.Game
 	preview *atom.Game
@@ -39,10 +58,27 @@ var (
 		atoms []atom.Loosetile
 		timer time.Time
 		tick  time.Time
+		taken time.Duration
+		name  []byte
+		blurb [][]byte
 	}
 	credits struct {
 		y int
 	}
+	hints struct {
+		busy   bool
+		serial int
+		result chan hintResult
+		none   time.Time
+	}
+	pars struct {
+		known  map[int]int
+		result chan parResult
+	}
+	packs struct {
+		list  []*atom.Pack
+		index int
+	}
 	level    int
 	state    int
 	newstate int
@@ -50,6 +86,8 @@ var (
 	justStarted bool
 	showCursor  bool
 
+	clock *atom.StepClock
+
 	fps sdlgfx.FPSManager
 )
 
@@ -60,30 +98,59 @@ func main() {
 	screen = atom.NewDisplay(conf, "Atomiks", true)
 	gfx = atom.LoadGFX(conf)
 	sfx = atom.LoadSFX(conf)
-	game = atom.NewGame(conf, screen, gfx, false)
+	input = atom.NewInput(conf)
+	pointer = atom.NewPointer(screen)
+	clock = atom.NewStepClock(time.Now(), time.Second/updateRate)
+	game = atom.NewGame(conf, screen, gfx, clock, false)
+	hints.result = make(chan hintResult, 1)
+	pars.known = make(map[int]int)
+	pars.result = make(chan parResult, 1)
+	packs.list = append(atom.FindPacks(conf), atom.DailyPack(time.Now()))
 
 	fps.Init()
-	fps.SetRate(60)
+	fps.SetRate(updateRate)
 
 	swtch(INTRO)
+	lag := atom.Lag{Last: time.Now(), Max: maxLag}
 	for {
 		if newstate != 0 {
 			swtch(newstate)
 			newstate = 0
 		}
 		event()
-		update()
-		blit()
+
+		for n := lag.Catch(time.Now(), clock.Step); n > 0; n-- {
+			if newstate != 0 {
+				swtch(newstate)
+				newstate = 0
+			}
+			update()
+		}
+
+		blit(lag.Alpha(clock.Step))
 		fps.Delay()
 	}
 }
 
+// The game updates at a fixed rate however fast it is drawn, the clock
+// steps once per update rather than following the wall clock so that
+// replays come out the same. When updates fall behind by more than
+// maxLag the game slows down instead of catching up all at once.
+const (
+	updateRate = 60
+	maxLag     = 250 * time.Millisecond
+)
+
 func swtch(newstate int) {
+	if replay.watch != nil && newstate != PLAY && newstate != WON {
+		stopReplay()
+	}
+
 	switch state = newstate; state {
 	case INTRO:
 		level = 1
 		justStarted = true
-		slider.Init(screen, []atom.Slide{
+		slider.Init(screen, clock, []atom.Slide{
 			{[]image.Image{gfx.Title}, 250 * 16 * time.Millisecond},
 			{[]image.Image{gfx.Info, gfx.Intro[0]}, 0},
 			{[]image.Image{gfx.Info, gfx.Intro[1]}, 0},
@@ -91,26 +158,47 @@ func swtch(newstate int) {
 		}, true)
 		sfx.PlayMusic(sfx.Title, 0)
 
+	case PACKS:
+		refreshDaily()
+
+	case OPTIONS:
+		options.index = 0
+		options.confirm = false
+		options.keys = false
+		options.binding = false
+
 	case SELECT:
-		preview = atom.NewGame(conf, screen, gfx, true)
+		preview = atom.NewGame(conf, screen, gfx, clock, true)
 		preview.Load(level)
+		replay.available = conf.HasReplay(level)
 
 	case PLAY:
 		sdlmixer.FadeOutMusic(2000)
 		showCursor = true
 		game.Load(level)
+		hints.serial++
+		findPar()
+		startRecording()
+		if replay.watch == nil {
+			conf.Record(level).Attempts++
+			saveConfig()
+		}
 
 	case WON:
-		if level == conf.MaxAuthLevel && conf.MaxAuthLevel < atom.LEVELS {
+		if level == conf.MaxAuthLevel && conf.MaxAuthLevel < conf.Pack.Len() && replay.watch == nil {
 			conf.MaxAuthLevel++
 		}
+		won.taken = game.Duration*time.Second - game.TimeEnd.Sub(clock.Now())
+		if won.taken < 0 {
+			won.taken = 0
+		}
 
 		won.atoms = won.atoms[:0]
 
 		f := &game.Field
 		for y := 0; y < f.Height; y++ {
 			for x := 0; x < f.Width; x++ {
-				if f.Type(x, y) == atom.ATOM {
+				if f.Type(x, y) == puzzle.ATOM {
 					won.atoms = append(won.atoms, atom.Loosetile{
 						Point: image.Pt(x, y),
 					})
@@ -118,7 +206,10 @@ func swtch(newstate int) {
 			}
 		}
 		shuffle(won.atoms)
-		won.tick = time.Now()
+		name, formula := game.Compound()
+		won.name = []byte(strings.TrimSpace(name + "  " + formula))
+		won.blurb = wrap(strings.ToUpper(game.Blurb), compoundWidth)
+		won.tick = clock.Now()
 		won.timer = won.tick.Add(40 * time.Millisecond)
 		showCursor = false
 
@@ -139,7 +230,7 @@ func swtch(newstate int) {
 				30 * time.Millisecond,
 			})
 		}
-		slider.Init(screen, slides, false)
+		slider.Init(screen, clock, slides, false)
 	}
 }
 
@@ -149,25 +240,32 @@ func event() {
 		if ev == nil {
 			break
 		}
-		switch ev := ev.(type) {
-		case sdl.QuitEvent:
+		if _, ok := ev.(sdl.QuitEvent); ok {
 			os.Exit(0)
+		}
 
-		case sdl.KeyDownEvent:
-			switch key := atom.Key(ev.Sym); key {
-			case atom.FULLSCREEN:
-				if !conf.Fullscreen {
-					screen.SetFullscreen(sdl.WINDOW_FULLSCREEN_DESKTOP)
-				} else {
-					screen.SetFullscreen(0)
-				}
-
-			case atom.NONE:
+		if kind, pt := pointer.Event(ev); kind != 0 {
+			evPointer(kind, pt)
+			continue
+		}
 
-			default:
-				evState(key)
+		if state == OPTIONS && options.binding {
+			if name := input.Name(ev); name != "" {
+				bindInput(name)
+				continue
 			}
 		}
+
+		switch key := input.Event(ev); key {
+		case atom.FULLSCREEN:
+			toggleFullscreen()
+			saveSettings()
+
+		case atom.NONE:
+
+		default:
+			evState(key)
+		}
 	}
 }
 
@@ -175,9 +273,19 @@ func evState(key int) {
 	switch state {
 	case INTRO, EXIT:
 		slider.Event(key)
+	case PACKS:
+		evPacks(key)
+	case OPTIONS:
+		evOptions(key)
 	case SELECT:
 		evSelect(key)
 	case PLAY:
+		if replay.watch != nil {
+			if key == atom.ESC {
+				newstate = SELECT
+			}
+			break
+		}
 		evPlay(key)
 	case WON:
 		if key == atom.ESC {
@@ -192,33 +300,75 @@ func evState(key int) {
 	}
 }
 
+// evPacks picks the level pack to play, the progress in each pack is
+// kept separately.
+func evPacks(key int) {
+	switch key {
+	case atom.ESC:
+		atom.DrawGFX(slider.Frame, screen, 0, 0)
+		newstate = EXIT
+	case atom.OPTIONS:
+		options.back = PACKS
+		newstate = OPTIONS
+	case atom.UP, atom.LEFT:
+		if packs.index > 0 {
+			packs.index--
+		}
+	case atom.DOWN, atom.RIGHT:
+		if packs.index < len(packs.list)-1 {
+			packs.index++
+		}
+	case atom.HOME:
+		packs.index = 0
+	case atom.END:
+		packs.index = len(packs.list) - 1
+	case atom.ENTER:
+		if p := packs.list[packs.index]; p != conf.Pack {
+			conf.SetPack(p)
+			pars.known = make(map[int]int)
+			level = 1
+		}
+		newstate = SELECT
+	}
+}
+
 func evSelect(key int) {
 	oldLevel := level
 	switch key {
 	case atom.ESC:
+		if len(packs.list) > 1 {
+			newstate = PACKS
+			break
+		}
 		atom.DrawGFX(slider.Frame, screen, 0, 0)
 		newstate = EXIT
+	case atom.OPTIONS:
+		options.back = SELECT
+		newstate = OPTIONS
 	case atom.LEFT:
 		if level > 1 {
 			level--
 		}
 	case atom.RIGHT:
-		if level < atom.LEVELS && (level < conf.MaxAuthLevel || conf.Unlocked) {
+		if level < conf.Pack.Len() && (level < conf.MaxAuthLevel || conf.Unlocked) {
 			level++
 		}
 	case atom.HOME:
 		level = 1
 	case atom.END:
 		level = conf.MaxAuthLevel
-		if conf.Unlocked || level > atom.LEVELS {
-			level = atom.LEVELS
+		if conf.Unlocked || level > conf.Pack.Len() {
+			level = conf.Pack.Len()
 		}
 	case atom.ENTER:
 		newstate = PLAY
+	case atom.REPLAY:
+		watchReplay()
 	}
 
 	if oldLevel != level {
 		preview.Load(level)
+		replay.available = conf.HasReplay(level)
 	}
 }
 
@@ -229,19 +379,21 @@ func evPlay(key int) {
 			newstate = EXIT
 		} else {
 			justStarted = false
-			game.TimeEnd = time.Now().Add(game.Duration * time.Second)
-			game.PreviewTick = time.Now()
+			game.TimeEnd = clock.Now().Add(game.Duration * time.Second)
+			game.PreviewTick = clock.Now()
+			startRecording()
 		}
 		return
 	}
+	recordAction(key)
 
 	switch key {
 	case atom.SPACE:
 		game.Paused = !game.Paused
 		if game.Paused {
-			game.PauseTime = game.TimeEnd.Sub(time.Now())
+			game.PauseTime = game.TimeEnd.Sub(clock.Now())
 		} else {
-			game.TimeEnd = time.Now().Add(game.PauseTime)
+			game.TimeEnd = clock.Now().Add(game.PauseTime)
 		}
 	}
 
@@ -261,20 +413,106 @@ func evPlay(key int) {
 	case atom.DOWN:
 		move(0, 1, key)
 	case atom.ENTER:
-		g := game
-		if g.Field.Type(g.Cursor.X, g.Cursor.Y) == atom.ATOM {
-			if g.Cursor.State == 0 {
-				g.Cursor.State = g.Cursor.Type
-				sfx.PlaySound(sfx.Selected, 0)
-			} else {
-				g.Cursor.State = 0
-			}
+		if game.Select() {
+			sfx.PlaySound(sfx.Selected, 0)
 		}
+	case atom.HINT:
+		requestHint()
+	case atom.UNDO:
+		finishMotion()
+		if m, ok := game.Undo(); ok {
+			slideAtom(m.To, m.From)
+		}
+	case atom.REDO:
+		finishMotion()
+		if m, ok := game.Redo(); ok {
+			slideAtom(m.From, m.To)
+		}
+	case atom.RESTART:
+		restart()
+	}
+}
+
+// restart reloads the level being played, the timer either starts
+// over or keeps counting down depending on the config.
+func restart() {
+	finishMotion()
+	timeEnd := game.TimeEnd
+	game.Load(game.Level)
+	hints.serial++
+	findPar()
+
+	now := clock.Now()
+	if conf.KeepTimer {
+		game.TimeEnd = timeEnd
+	} else {
+		game.TimeEnd = now.Add(game.Duration * time.Second)
+	}
+	game.PreviewTick = now
+
+	if replay.watch == nil {
+		r := conf.Record(game.Level)
+		r.Restarts++
+		r.Attempts++
+		saveConfig()
+	}
+}
+
+func toggleFullscreen() {
+	conf.Fullscreen = !conf.Fullscreen
+	if conf.Fullscreen {
+		screen.SetFullscreen(sdl.WINDOW_FULLSCREEN_DESKTOP)
+	} else {
+		screen.SetFullscreen(0)
+	}
+	conf.Set("fullscreen")
+}
+
+func saveSettings() {
+	if replay.watch != nil {
+		swapOptions()
+		defer swapOptions()
+	}
+	err := conf.SaveSettings()
+	if err != nil {
+		sdl.Log("%v", err)
+	}
+}
+
+func saveConfig() {
+	err := conf.Save()
+	if err == nil {
+		sdl.Log("Saved config")
+	} else {
+		sdl.Log("%v", err)
+	}
+}
+
+// noHintTime is how long the game tells there is no hint when the
+// solver can't find a way to win from the field.
+const noHintTime = 2 * time.Second
+
+// requestHint starts solving the current field in the background,
+// the answer is picked up by playUpdate. The search is kept short,
+// building the molecule an atom at a time if it gives up.
+func requestHint() {
+	g := game
+	if hints.busy || g.ShowHint || g.Motion.Moving || g.Loosing || replay.watch != nil {
+		return
 	}
+
+	hints.busy = true
+	p := g.Puzzle
+	serial := hints.serial
+	go func() {
+		s := puzzle.Solver{Limit: 50000, Weight: 3, Build: 1000000}
+		m, err := p.Hint(&s)
+		hints.result <- hintResult{serial, p.Moves, m, err}
+	}()
 }
 
 func move(dx, dy, dir int) {
-	if game.Cursor.Moving || game.Loosing {
+	if game.Motion.Moving || game.Loosing {
 		return
 	}
 
@@ -286,84 +524,119 @@ func move(dx, dy, dir int) {
 }
 
 func moveAtom(dir int) {
+	from := game.Cursor.Point
+	if game.Slide(dir) == 0 {
+		return
+	}
+	slideAtom(from, game.Cursor.Point)
+}
+
+// slideAtom animates the atom that the puzzle has already moved
+// from one square to another.
+func slideAtom(from, to image.Point) {
 	g := game
-	d := g.MovedDistance(dir)
-	if d == 0 {
+	l := &g.Loose
+
+	d := to.Sub(from)
+	l.Atom = g.Field.Index(to.X, to.Y)
+	l.X = g.Offset.X + from.X*atom.TILESIZE
+	l.Y = g.Offset.Y + from.Y*atom.TILESIZE
+	l.Mx, l.My = sign(d.X), sign(d.Y)
+	l.Dx, l.Dy = to.X, to.Y
+	l.Ex = g.Offset.X + to.X*atom.TILESIZE
+	l.Ey = g.Offset.Y + to.Y*atom.TILESIZE
+
+	g.ShowHint = false
+	hints.serial++
+	if animStep() == 0 {
+		l.Atom = 0
+		sfx.PlaySound(sfx.Bzzz, 0)
 		return
 	}
+	g.Loosing = true
+	sfx.PlaySound(sfx.Bzzz, -1)
+}
 
-	if !conf.NoLose {
-		g.Score -= 5
-		if g.Score < 0 {
-			g.Score = 0
-		}
+// animStep returns how many pixels the cursor and atoms move in an
+// update, or zero if they move at once.
+func animStep() int {
+	if conf.Speed <= 0 {
+		return 0
+	}
+	n := conf.Speed * atom.TILESIZE / updateRate
+	if n < 1 {
+		n = 1
 	}
+	return n
+}
 
-	c := &g.Cursor
-	l := &g.Loose
-	l.Atom = g.Field.Index(c.X, c.Y)
-	l.X = g.Offset.X + c.X*atom.TILESIZE
-	l.Y = g.Offset.Y + c.Y*atom.TILESIZE
-	l.Ex, l.Ey = l.X, l.Y
-	l.Mx, l.My = 0, 0
-	l.Dx, l.Dy = c.X, c.Y
-	g.Field.Set(c.X, c.Y, atom.FREE)
-	g.Cursor.Sx = 0
-	g.Cursor.Sy = 0
-
-	dd := d
-	d *= atom.TILESIZE
-	switch dir {
-	case atom.LEFT:
-		l.Mx = -1
-		l.Ex -= d
-		l.Dx = c.X - dd
-	case atom.RIGHT:
-		l.Mx = 1
-		l.Ex += d
-		l.Dx = c.X + dd
-	case atom.DOWN:
-		l.My = 1
-		l.Ey += d
-		l.Dy = c.Y + dd
-	case atom.UP:
-		l.My = -1
-		l.Ey -= d
-		l.Dy = c.Y - dd
+// findPar works out the fewest moves needed for the level in the
+// background unless the level file already says, answers are kept
+// for the rest of the session.
+func findPar() {
+	g := game
+	if g.Par > 0 {
+		return
+	}
+	if par, ok := pars.known[g.Level]; ok {
+		if par > 0 {
+			g.Par = par
+		}
+		return
 	}
 
-	g.Loosing = true
-	sfx.PlaySound(sfx.Bzzz, -1)
+	pars.known[g.Level] = 0
+	p := g.Puzzle
+	pack := conf.Pack
+	level := g.Level
+	go func() {
+		par := -1
+		moves, err := puzzle.Solve(&p, 200000)
+		if err == nil {
+			par = len(moves)
+		}
+		pars.result <- parResult{pack, level, par}
+	}()
 }
 
-func moveCursor(mx, my int) {
+// finishMotion skips to the end of any cursor or atom animation.
+func finishMotion() {
 	g := game
-	f := &g.Field
-	c := &g.Cursor
+	m := &g.Motion
+	m.Sx, m.Sy = 0, 0
+	m.Moving = false
+
+	if g.Loosing {
+		g.Loose.Atom = 0
+		g.Loosing = false
+		sdlmixer.HaltChannel(0)
+	}
+}
 
-	x := c.X + mx
-	y := c.Y + my
-	if x < 0 || y < 0 || f.At(x, y) == 0 {
+func moveCursor(mx, my int) {
+	g := game
+	m := &g.Motion
+	if !g.MoveCursor(mx, my) || animStep() == 0 {
 		return
 	}
 
-	c.Mx = mx
-	c.My = my
-	c.Sx = 0
-	c.Sy = 0
-	c.Ex = mx * atom.TILESIZE
-	c.Ey = my * atom.TILESIZE
-
-	c.Moving = true
+	m.Mx = mx
+	m.My = my
+	m.Sx = -mx * atom.TILESIZE
+	m.Sy = -my * atom.TILESIZE
+	m.Moving = true
 }
 
 func update() {
 	switch state {
 	case INTRO:
 		if slider.Update() {
-			if slider.Quit {
+			switch {
+			case slider.Quit:
 				newstate = EXIT
-			} else {
+			case len(packs.list) > 1:
+				newstate = PACKS
+			default:
 				newstate = SELECT
 			}
 		}
@@ -385,114 +658,149 @@ func update() {
 			os.Exit(0)
 		}
 	}
+	clock.Advance()
 }
 
 func playUpdate() {
 	g := game
-	c := &g.Cursor
+	m := &g.Motion
 	l := &g.Loose
 
+	select {
+	case r := <-hints.result:
+		hints.busy = false
+		switch {
+		case r.serial != hints.serial || r.moves != g.Moves:
+		case r.err != nil:
+			hints.none = clock.Now().Add(noHintTime)
+		default:
+			showHint(r.move)
+		}
+	default:
+	}
+
+	select {
+	case r := <-pars.result:
+		if r.pack != conf.Pack {
+			break
+		}
+		pars.known[r.level] = r.par
+		if r.level == g.Level && r.par > 0 {
+			g.Par = r.par
+		}
+	default:
+	}
+
+	if replay.watch != nil {
+		replayUpdate()
+	}
+
 	if g.Paused {
 		return
 	}
 
-	if now := time.Now(); now.After(g.TimeEnd) && !conf.NoLose {
+	g.Hold()
+	if g.TimedOut() {
 		newstate = TIMEOUT
 	}
 
 	switch {
-	case c.Moving:
-		c.Sx += c.Mx * 8
-		c.Sy += c.My * 8
-		if c.Sx == c.Ex && c.Sy == c.Ey {
-			c.Moving = false
-			c.X += c.Mx
-			c.Y += c.My
-			c.Sx = 0
-			c.Sy = 0
-		}
+	case m.Moving:
+		m.Step(animStep())
 
 	case g.Loosing:
-		mx := l.Mx * 8
-		my := l.My * 8
-		l.X += mx
-		l.Y += my
-		c.Sx += mx
-		c.Sy += my
-		if l.X == l.Ex && l.Y == l.Ey {
-			x := l.Dx
-			y := l.Dy
-			c.Sx = 0
-			c.Sy = 0
-			g.Field.Set(x, y, l.Atom|atom.ATOM)
+		if l.Step(animStep()) {
 			l.Atom = 0
-			c.X = x
-			c.Y = y
 			g.Loosing = false
 			sdlmixer.HaltChannel(0)
 		}
 
 	case g.Won():
 		newstate = WON
+
+	default:
+		g.Follow(g.Cursor.Point)
 	}
 }
 
 func wonUpdate() {
 	if len(won.atoms) == 0 {
-		for i := 0; i < 3; i++ {
-			game.Score += 10
-			won.tick = won.tick.Add(1 * time.Second)
-			if won.tick.After(game.TimeEnd) {
-				if conf.MaxAuthLevel < atom.LEVELS {
-					if game.Score >= game.Hiscore {
-						game.Hiscore = game.Score
-						if game.Hiscore >= conf.Hiscores[level-1] {
-							conf.Hiscores[level-1] = game.Hiscore
-						}
-					}
-					level++
-					newstate = SELECT
-				} else {
-					newstate = CREDITS
-				}
-				err := conf.Save()
-				if err == nil {
-					sdl.Log("Saved config")
-				} else {
-					sdl.Log("%v", err)
-				}
-			}
+		var done bool
+		if won.tick, done = game.Tally(won.tick, 3); !done {
+			return
+		}
+		if replay.watch != nil {
+			checkReplay()
+			newstate = SELECT
+			return
 		}
+
+		if game.Score >= game.Hiscore {
+			game.Hiscore = game.Score
+		}
+		r := conf.Record(level)
+		if r.Completed == nil || game.Score >= r.BestScore {
+			saveReplay()
+		}
+		r.Win(game.Score, won.taken, game.Moves, game.Hints > 0, time.Now())
+		switch {
+		case conf.Pack.Daily:
+			newstate = SELECT
+		case conf.MaxAuthLevel < conf.Pack.Len():
+			level++
+			newstate = SELECT
+		default:
+			newstate = CREDITS
+		}
+		saveConfig()
 	} else {
-		if now := time.Now(); now.After(won.timer) {
+		if now := clock.Now(); now.After(won.timer) {
 			won.timer = now.Add(40 * time.Millisecond)
 
 			a := &won.atoms[0]
 			if a.Atom++; a.Atom >= len(gfx.Explosion) {
-				game.Field.Set(a.X, a.Y, atom.FREE)
+				game.Field.Set(a.X, a.Y, puzzle.FREE)
 				won.atoms = won.atoms[1:]
 				if len(won.atoms) == 0 {
-					won.timer = time.Now()
+					won.timer = now
 				}
 			}
 		}
 	}
 }
 
-func blit() {
+// blit draws the screen when alpha of the time to the next update has
+// gone by, things in motion are drawn that far along.
+func blit(alpha float64) {
 	screen.Clear()
 	switch state {
 	case INTRO, EXIT:
 		slider.Draw()
+	case PACKS:
+		blitPacks()
+	case OPTIONS:
+		blitOptions()
 	case SELECT:
 		preview.DrawPreview()
+		if conf.Pack.Daily {
+			blitDaily()
+		}
+		if replay.available {
+			blitReplayKey()
+		}
 	case PLAY:
-		blitPlay(time.Now())
+		blitPlay(clock.Now(), alpha)
+		if replay.watch != nil {
+			blitFont1([]byte("REPLAY"), 84, 4)
+		}
 	case WON:
-		blitPlay(won.tick)
+		blitPlay(won.tick, 0)
+		blitCompound()
 		if len(won.atoms) > 0 {
 			a := &won.atoms[0]
 			game.DrawTile(a.X, a.Y, gfx.Explosion[a.Atom])
+		} else {
+			blitStars(puzzle.Stars(game.Moves, game.Par))
 		}
 	case TIMEOUT:
 		atom.DrawGFX(screen, gfx.Timeout, 0, 0)
@@ -502,6 +810,57 @@ func blit() {
 	screen.Flush()
 }
 
+// Layout of the pack list.
+const (
+	packsTop  = 40
+	packsRows = 15
+	packsGap  = 11
+)
+
+// packsFirst returns the first pack shown, the list scrolls to keep the
+// selected one in view.
+func packsFirst() int {
+	if packs.index >= packsRows {
+		return packs.index - packsRows + 1
+	}
+	return 0
+}
+
+// blitPacks lists the level packs with how far the player got in the
+// one being played.
+func blitPacks() {
+	atom.DrawGFX(screen, gfx.Info, 0, 0)
+
+	const (
+		top  = packsTop
+		rows = packsRows
+		gap  = packsGap
+	)
+	first := packsFirst()
+	for i := first; i < len(packs.list) && i < first+rows; i++ {
+		p := packs.list[i]
+		y := top + (i-first)*gap
+		if i == packs.index {
+			atom.DrawRect(screen, 40*2, (y-3)*2, 240*2, gap*2, 0x30, 0x30, 0x80, 255)
+		}
+		blitFont1([]byte(p.Name), 48, y)
+
+		info := fmt.Sprintf("%d LEVELS", p.Len())
+		switch {
+		case p.Daily:
+			info = dailyInfo()
+		case p == conf.Pack:
+			info = fmt.Sprintf("LEVEL %d OF %d", conf.MaxAuthLevel, p.Len())
+		}
+		blitFont1([]byte(info), 272-font1Size([]byte(info)), y)
+	}
+
+	if p := packs.list[packs.index]; p.Author != "" {
+		author := []byte("BY " + p.Author)
+		blitFont1(author, 160-font1Size(author)/2, top+rows*gap+4)
+	}
+}
+
 func blitCredits() {
 	atom.DrawGFX(screen, gfx.Info, 0, 0)
 	r := gfx.Credit.Bounds()
@@ -512,7 +871,7 @@ func blitCredits() {
 	atom.DrawGFXPartial(screen, gfx.Credit, x, credits.y, w, h, 0, y)
 }
 
-func blitPlay(now time.Time) {
+func blitPlay(now time.Time, alpha float64) {
 	g := game
 
 	if g.Paused {
@@ -523,10 +882,6 @@ func blitPlay(now time.Time) {
 	var timeLeft time.Duration
 	var previewTick int64
 
-	if conf.NoLose {
-		g.TimeEnd = time.Now().Add((g.Duration + 1) * time.Second)
-	}
-
 	if justStarted {
 		timeLeft = g.Duration * time.Second
 	} else {
@@ -537,44 +892,75 @@ func blitPlay(now time.Time) {
 	}
 
 	g.DrawField()
-	if g.Loose.Atom != 0 {
-		atom.DrawGFX(screen, gfx.Atom[g.Loose.Atom], g.Loose.X, g.Loose.Y)
+	loose := g.Loose.Lerp(animStep(), alpha)
+	if g.Loosing {
+		g.DrawTile(g.Loose.Dx, g.Loose.Dy, gfx.Empty)
+		atom.DrawGFX(screen, gfx.Atom[g.Loose.Atom], loose.X, loose.Y)
+	}
+
+	if g.ShowHint && !g.Loosing && previewTick == 0 {
+		g.DrawMark(g.Hint.From.X, g.Hint.From.Y, color.RGBA{255, 255, 255, 96})
+		g.DrawMark(g.Hint.To.X, g.Hint.To.Y, color.RGBA{255, 255, 255, 48})
+	}
+
+	if now.Before(hints.none) {
+		text := []byte("NO HINT")
+		blitFont1(text, 80+(240-font1Size(text))/2, 4)
 	}
 
 	if showCursor {
 		r := gfx.Cursor[0].Bounds()
-		x := g.Offset.X + g.Cursor.X*r.Dx() + g.Cursor.Sx
-		y := g.Offset.Y + g.Cursor.Y*r.Dy() + g.Cursor.Sy
+		sx, sy := g.Motion.Lerp(animStep(), alpha)
+		x := g.Offset.X + g.Cursor.X*r.Dx() + sx
+		y := g.Offset.Y + g.Cursor.Y*r.Dy() + sy
+		if g.Loosing {
+			x, y = loose.X, loose.Y
+		}
 		atom.DrawGFX(screen, gfx.Cursor[g.Cursor.State], x, y)
 	}
 
+	r1 := gfx.Font1[0].Bounds()
+	r2 := gfx.Font2[0].Bounds()
 	r3 := gfx.Font3[0].Bounds()
+	gap := r2.Dy() + 6
 	x := atom.TILESIZE / 2
 	y := atom.TILESIZE / 2
 	blitString("HISCORE", x, y)
+	if g.Hinted {
+		blitFont1([]byte("HINT"), x+7*r3.Dx()+2, y+r3.Dy()-r1.Dy())
+	}
 
 	y += int(float64(r3.Dy()) * 1.4)
 	blitNumber(g.Hiscore, x, y)
 
-	y += atom.TILESIZE * 2
+	y += gap
 	blitString("SCORE", x, y)
 
 	y += int(float64(r3.Dy()) * 1.4)
 	blitNumber(g.Score, x, y)
 
-	y += atom.TILESIZE * 2
+	y += gap
+	blitString("MOVES", x, y)
+	if g.Par > 0 {
+		blitFont1([]byte(fmt.Sprint("PAR ", g.Par)), x+5*r3.Dx()+3, y+r3.Dy()-r1.Dy())
+	}
+
+	y += int(float64(r3.Dy()) * 1.4)
+	blitNumber(g.Moves, x, y)
+
+	y += gap
 	blitString("LEVEL", x, y)
 
 	y += int(float64(r3.Dy()) * 1.4)
-	atom.DrawGFX(screen, gfx.Font2[g.Level/10], x, y)
-	r2 := gfx.Font2[0].Bounds()
-	x += r2.Dx()
-	atom.DrawGFX(screen, gfx.Font2[g.Level%10], x, y)
+	blitDigits(fmt.Sprintf("%02d", g.Level), x, y)
 
 	x = atom.TILESIZE / 2
-	y += atom.TILESIZE * 2
+	y += gap
 	blitString("TIME", x, y)
 
+	if timeLeft > puzzle.MaxDuration*time.Second {
+		timeLeft = puzzle.MaxDuration * time.Second
+	}
 	min := int(timeLeft.Minutes())
 	sec := int(timeLeft.Seconds())
 	y += int(float64(r3.Dy()) * 1.4)
@@ -590,7 +976,6 @@ func blitPlay(now time.Time) {
 	y = 240 - 71
 	atom.DrawGFX(screen, gfx.Preview[previewTick], x, y)
 
-	r1 := gfx.Font1[0].Bounds()
 	y = 181
 	blitDesc(g.Desc[0][:], y)
 	y += r1.Dy() + 2
@@ -603,13 +988,77 @@ func blitPlay(now time.Time) {
 	}
 }
 
+// blitStars draws the rating for a won level centered over the field.
+func blitStars(n int) {
+	if n == 0 {
+		return
+	}
+
+	r := gfx.Star[0].Bounds()
+	w := 3*r.Dx() + 2*4
+	x := 80 + (240-w)/2
+	y := 100
+	for i := 0; i < 3; i++ {
+		star := gfx.Star[0]
+		if i >= n {
+			star = gfx.Star[1]
+		}
+		atom.DrawGFX(screen, star, x, y)
+		x += r.Dx() + 4
+	}
+}
+
+// Room for the name and formula of the molecule on the field when a
+// level is won.
+const (
+	compoundWidth = 224
+	compoundLines = 3
+)
+
+// blitCompound names the molecule that was built along with its formula
+// and what the level tells about it, at the top of the field.
+func blitCompound() {
+	if len(won.name) == 0 {
+		return
+	}
+
+	n := len(won.blurb)
+	if n > compoundLines {
+		n = compoundLines
+	}
+	const gap = 8
+	x, y := 80+(240-compoundWidth)/2, 4
+	atom.DrawRect(screen, (x-4)*2, (y-3)*2, (compoundWidth+8)*2, ((n+1)*gap+4)*2, 0, 0, 0, 192)
+	blitFont1(won.name, 200-font1Size(won.name)/2, y)
+	for _, line := range won.blurb[:n] {
+		y += gap
+		blitFont1(line, 200-font1Size(line)/2, y)
+	}
+}
+
+// wrap breaks text into lines no wider than width.
+func wrap(text string, width int) [][]byte {
+	var lines [][]byte
+	for _, word := range strings.Fields(text) {
+		if n := len(lines) - 1; n >= 0 {
+			line := append(append([]byte{}, lines[n]...), ' ')
+			line = append(line, word...)
+			if font1Size(line) <= width {
+				lines[n] = line
+				continue
+			}
+		}
+		lines = append(lines, []byte(word))
+	}
+	return lines
+}
+
 func blitString(text string, x, y int) {
 	for _, ch := range text {
 		ch -= 'A'
-		if !(0 <= ch && ch < rune(len(gfx.Font3))) {
-			ch = 27
+		if 0 <= ch && ch < rune(len(gfx.Font3)) {
+			atom.DrawGFX(screen, gfx.Font3[ch], x, y)
 		}
-		atom.DrawGFX(screen, gfx.Font3[ch], x, y)
 
 		r := gfx.Font3[0].Bounds()
 		x += r.Dx()
@@ -617,7 +1066,10 @@ func blitString(text string, x, y int) {
 }
 
 func blitNumber(n, x, y int) {
-	str := fmt.Sprint(n)
+	blitDigits(fmt.Sprint(n), x, y)
+}
+
+func blitDigits(str string, x, y int) {
 	for _, ch := range str {
 		atom.DrawGFX(screen, gfx.Font2[ch-'0'], x, y)
 		r := gfx.Font2[0].Bounds()
@@ -626,8 +1078,11 @@ func blitNumber(n, x, y int) {
 }
 
 func blitDesc(desc []byte, y int) {
-	x := 36 - font1Size(desc)/2
-	for _, ch := range desc {
+	blitFont1(desc, 36-font1Size(desc)/2, y)
+}
+
+func blitFont1(text []byte, x, y int) {
+	for _, ch := range text {
 		if ch == 0 {
 			break
 		}
@@ -641,6 +1096,7 @@ func blitDesc(desc []byte, y int) {
 var font1Width = []int{
 	5, 5, 4, 5, 4, 4, 5, 5, 2, 4, 4, 4, 6, 5, 5, 5, 5, 5,
 	5, 4, 5, 4, 6, 4, 5, 4, 5, 4, 4, 4, 4, 4, 5, 4, 5, 5,
+	3,
 }
 
 func font1Size(buf []byte) int {
@@ -663,7 +1119,17 @@ func ascii2font1(ch byte) int {
 	case '0' <= c && c <= '9':
 		return 26 + (c - '0')
 	}
-	return 35
+	return 36
+}
+
+func sign(x int) int {
+	switch {
+	case x < 0:
+		return -1
+	case x > 0:
+		return 1
+	}
+	return 0
 }
 
 func shuffle(l []atom.Loosetile) {
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/qeedquan/go-atomiks/atom"
+)
+
+// refreshDaily swaps the daily challenge in the pack list for a new one
+// once the day is over.
+func refreshDaily() {
+	now := time.Now()
+	for i, p := range packs.list {
+		if p.Daily && !p.Today(now) {
+			packs.list[i] = atom.DailyPack(now)
+		}
+	}
+}
+
+// dailyInfo tells the streak in the pack list.
+func dailyInfo() string {
+	return fmt.Sprintf("STREAK %d", conf.DailyStreak(time.Now()))
+}
+
+// blitDaily shows the streak and the best result of the day on the
+// level selection screen.
+func blitDaily() {
+	text := []byte(dailyInfo())
+	if r := conf.Levels[conf.Pack.LevelID(1)]; r != nil && r.Completed != nil {
+		text = append(text, fmt.Sprintf("  BEST %d IN %d MOVES", r.BestScore, r.FewestMoves)...)
+	}
+	blitFont1(text, 160-font1Size(text)/2, 210)
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/qeedquan/go-atomiks/atom"
+)
+
+const (
+	optMusic = iota
+	optEffects
+	optFullscreen
+	optScale
+	optSpeed
+	optKeys
+	optReset
+	numOptions
+)
+
+const maxScale = 4
+
+// speeds are the animation speeds to pick from in squares per second,
+// zero moves at once.
+var speeds = []int{15, atom.DefaultSpeed, 60, 120, 0}
+
+// Layout of the options and key bindings pages.
+const (
+	optionsTop = 64
+	optionsGap = 22
+	keysTop    = 42
+	keysGap    = 11
+)
+
+var optionNames = [numOptions]string{
+	optMusic:      "MUSIC",
+	optEffects:    "EFFECTS",
+	optFullscreen: "FULLSCREEN",
+	optScale:      "WINDOW SIZE",
+	optSpeed:      "SPEED",
+	optKeys:       "KEYS",
+	optReset:      "RESET PROGRESS",
+}
+
+var options struct {
+	index   int
+	back    int
+	confirm bool
+	keys    bool
+	key     int
+	binding bool
+}
+
+func evOptions(key int) {
+	if options.keys {
+		evKeys(key)
+		return
+	}
+
+	if key != atom.ENTER {
+		options.confirm = false
+	}
+
+	switch key {
+	case atom.ESC:
+		saveSettings()
+		newstate = options.back
+	case atom.UP:
+		if options.index > 0 {
+			options.index--
+		}
+	case atom.DOWN:
+		if options.index < numOptions-1 {
+			options.index++
+		}
+	case atom.HOME:
+		options.index = 0
+	case atom.END:
+		options.index = numOptions - 1
+	case atom.LEFT:
+		changeOption(-1)
+	case atom.RIGHT:
+		changeOption(1)
+	case atom.ENTER:
+		switch options.index {
+		case optFullscreen:
+			toggleFullscreen()
+		case optKeys:
+			options.keys = true
+			options.key = 0
+		case optReset:
+			if options.confirm {
+				conf.ResetProgress()
+				saveConfig()
+				level = 1
+			}
+			options.confirm = !options.confirm
+		}
+	}
+}
+
+// changeOption steps the selected option up or down.
+func changeOption(dir int) {
+	const volumeStep = atom.MaxVolume / 16
+
+	switch options.index {
+	case optMusic:
+		sfx.SetMusicVolume(conf.Music + dir*volumeStep)
+		conf.Set("music")
+	case optEffects:
+		sfx.SetEffectsVolume(conf.Effects + dir*volumeStep)
+		conf.Set("effects")
+		sfx.PlaySound(sfx.Selected, 0)
+	case optFullscreen:
+		toggleFullscreen()
+	case optSpeed:
+		i := 0
+		for i < len(speeds)-1 && speeds[i] != conf.Speed {
+			i++
+		}
+		if i += dir; 0 <= i && i < len(speeds) {
+			conf.Speed = speeds[i]
+			conf.Set("speed")
+		}
+	case optScale:
+		scale := conf.Scale + dir
+		if 1 <= scale && scale <= maxScale {
+			conf.Scale = scale
+			conf.Set("scale")
+			if !conf.Fullscreen {
+				screen.SetScale(scale)
+			}
+		}
+	}
+}
+
+func blitOptions() {
+	atom.DrawGFX(screen, gfx.Info, 0, 0)
+	if options.keys {
+		blitKeys()
+		return
+	}
+
+	r2 := gfx.Font2[0].Bounds()
+	r3 := gfx.Font3[0].Bounds()
+	title := "OPTIONS"
+	blitString(title, 160-len(title)*r3.Dx()/2, 40)
+
+	const (
+		top = optionsTop
+		gap = optionsGap
+	)
+	for i, name := range optionNames {
+		y := top + i*gap
+		if i == options.index {
+			atom.DrawRect(screen, 40*2, (y-4)*2, 240*2, (r2.Dy()+8)*2, 0x30, 0x30, 0x80, 255)
+		}
+
+		ty := y + (r2.Dy()-r3.Dy())/2
+		if i == optReset && options.confirm {
+			name = "PRESS ENTER AGAIN"
+		}
+		blitString(name, 48, ty)
+
+		switch i {
+		case optMusic:
+			blitNumber(conf.Music*100/atom.MaxVolume, 224, y)
+		case optEffects:
+			blitNumber(conf.Effects*100/atom.MaxVolume, 224, y)
+		case optFullscreen:
+			if conf.Fullscreen {
+				blitString("ON", 224, ty)
+			} else {
+				blitString("OFF", 224, ty)
+			}
+		case optScale:
+			blitNumber(conf.Scale, 224, y)
+		case optSpeed:
+			if conf.Speed <= 0 {
+				blitString("INSTANT", 224, ty)
+			} else {
+				blitNumber(conf.Speed, 224, y)
+			}
+		}
+	}
+}
+
+// evKeys picks an action to bind, the next key or button pressed is
+// bound to it.
+func evKeys(key int) {
+	n := len(atom.Actions)
+	switch key {
+	case atom.ESC:
+		options.keys = false
+	case atom.UP:
+		if options.key > 0 {
+			options.key--
+		}
+	case atom.DOWN:
+		if options.key < n {
+			options.key++
+		}
+	case atom.HOME:
+		options.key = 0
+	case atom.END:
+		options.key = n
+	case atom.ENTER:
+		if options.key == n {
+			conf.ResetBindings()
+			input.Bindings = conf.Bindings()
+			saveSettings()
+		} else {
+			options.binding = true
+		}
+	}
+}
+
+// bindInput binds the input that was pressed to the selected action,
+// escape cancels.
+func bindInput(name string) {
+	options.binding = false
+	if input.Bindings[name] == atom.ESC && !atom.IsPad(name) {
+		return
+	}
+	conf.Bind(name, atom.Actions[options.key])
+	input.Bindings = conf.Bindings()
+	saveSettings()
+}
+
+// blitKeys shows the keys and game controller buttons bound to each
+// action.
+func blitKeys() {
+	r3 := gfx.Font3[0].Bounds()
+	title := "KEYS"
+	blitString(title, 160-len(title)*r3.Dx()/2, 24)
+
+	const (
+		top = keysTop
+		gap = keysGap
+	)
+	for i, a := range atom.Actions {
+		y := top + i*gap
+		if i == options.key {
+			atom.DrawRect(screen, 40*2, (y-3)*2, 240*2, gap*2, 0x30, 0x30, 0x80, 255)
+		}
+		blitFont1([]byte(atom.ActionName(a)), 48, y)
+
+		if i == options.key && options.binding {
+			blitFont1([]byte("PRESS A KEY OR BUTTON"), 120, y)
+			continue
+		}
+		var key, pad string
+		for _, name := range input.Bindings.Inputs(a) {
+			if !atom.IsPad(name) && key == "" {
+				key = name
+			}
+			if atom.IsPad(name) && pad == "" {
+				pad = strings.TrimPrefix(name, "pad:")
+			}
+		}
+		blitFont1([]byte(key), 120, y)
+		blitFont1([]byte(pad), 272-font1Size([]byte(pad)), y)
+	}
+
+	n := len(atom.Actions)
+	y := top + n*gap + 4
+	if options.key == n {
+		atom.DrawRect(screen, 40*2, (y-3)*2, 240*2, gap*2, 0x30, 0x30, 0x80, 255)
+	}
+	blitFont1([]byte("RESET KEYS"), 48, y)
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"image"
+
+	"github.com/qeedquan/go-atomiks/atom"
+	"github.com/qeedquan/go-atomiks/puzzle"
+)
+
+// drag follows a press on the board until it is released.
+var drag struct {
+	atom     bool
+	slid     bool
+	deselect bool
+}
+
+// evPointer handles mouse clicks and touches, pressing mostly does what
+// enter would.
+func evPointer(kind int, pt image.Point) {
+	if state == PLAY {
+		evPointerPlay(kind, pt)
+		return
+	}
+
+	if kind != atom.PRESS {
+		return
+	}
+	switch state {
+	case INTRO, EXIT, TIMEOUT, CREDITS:
+		evState(atom.ENTER)
+	case WON:
+		evState(atom.ESC)
+	case PACKS:
+		if pt.Y < packsTop-3 {
+			break
+		}
+		i := packsFirst() + (pt.Y-(packsTop-3))/packsGap
+		pickRow(&packs.index, i, len(packs.list), evPacks)
+	case OPTIONS:
+		evPointerOptions(pt)
+	case SELECT:
+		left, right := preview.PreviewArrows()
+		switch {
+		case pt.In(left.Inset(-4)):
+			evSelect(atom.LEFT)
+		case pt.In(right.Inset(-4)):
+			evSelect(atom.RIGHT)
+		default:
+			evSelect(atom.ENTER)
+		}
+	}
+}
+
+func evPointerOptions(pt image.Point) {
+	if options.binding {
+		return
+	}
+	if !options.keys {
+		if pt.Y >= optionsTop-4 {
+			i := (pt.Y - (optionsTop - 4)) / optionsGap
+			pickRow(&options.index, i, numOptions, evOptions)
+		}
+		return
+	}
+
+	n := len(atom.Actions)
+	switch reset := keysTop + n*keysGap + 4 - 3; {
+	case pt.Y >= reset:
+		if pt.Y < reset+keysGap {
+			pickRow(&options.key, n, n+1, evKeys)
+		}
+	case pt.Y >= keysTop-3:
+		pickRow(&options.key, (pt.Y-(keysTop-3))/keysGap, n+1, evKeys)
+	}
+}
+
+// pickRow selects row i of a list, or presses enter if it already is.
+func pickRow(index *int, i, n int, ev func(int)) {
+	switch {
+	case i < 0 || i >= n:
+	case i == *index:
+		ev(atom.ENTER)
+	default:
+		*index = i
+	}
+}
+
+// evPointerPlay selects the atom pressed on, it then slides by dragging
+// it or pressing a square in line with it. Pressing it again without
+// dragging lets go of it.
+func evPointerPlay(kind int, pt image.Point) {
+	g := game
+	c := &g.Cursor
+	if replay.watch != nil {
+		return
+	}
+	if kind == atom.PRESS {
+		switch {
+		case justStarted:
+			evPlay(atom.ENTER)
+			return
+		case g.Paused:
+			evPlay(atom.SPACE)
+			return
+		}
+	}
+	if justStarted || g.Paused {
+		return
+	}
+
+	switch kind {
+	case atom.PRESS:
+		drag.atom, drag.slid, drag.deselect = false, false, false
+		sq, ok := g.Square(pt)
+		if !ok {
+			return
+		}
+
+		switch {
+		case c.State != 0 && sq == c.Point:
+			drag.atom = true
+			drag.deselect = true
+		case c.State != 0 && (sq.X == c.X || sq.Y == c.Y):
+			slide(direction(sq.Sub(c.Point)))
+		case g.Field.Type(sq.X, sq.Y) == puzzle.ATOM:
+			pick(sq)
+			drag.atom = true
+		case g.Field.At(sq.X, sq.Y) != 0:
+			pick(sq)
+		}
+
+	case atom.DRAG:
+		d := pointer.Moved()
+		if !drag.atom || drag.slid {
+			return
+		}
+		if abs(d.X) < atom.TILESIZE/2 && abs(d.Y) < atom.TILESIZE/2 {
+			return
+		}
+		drag.slid = true
+		slide(direction(d))
+
+	case atom.RELEASE:
+		if drag.deselect && !drag.slid && c.State != 0 {
+			evPlay(atom.ENTER)
+		}
+		drag.atom = false
+	}
+}
+
+// direction returns the direction d mostly goes in.
+func direction(d image.Point) int {
+	if abs(d.X) > abs(d.Y) {
+		if d.X > 0 {
+			return atom.RIGHT
+		}
+		return atom.LEFT
+	}
+	if d.Y > 0 {
+		return atom.DOWN
+	}
+	return atom.UP
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
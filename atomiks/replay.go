@@ -0,0 +1,186 @@
+package main
+
+import (
+	"image"
+	"time"
+
+	"github.com/qeedquan/go-atomiks/atom"
+	"github.com/qeedquan/go-atomiks/puzzle"
+	"github.com/qeedquan/go-media/sdl"
+)
+
+// replay records what is done in the level being played, the best win
+// on each level is kept and can be watched from the level selection.
+var replay struct {
+	rec       atom.Replay
+	watch     *atom.Replay
+	next      int
+	start     int
+	available bool
+	options   struct {
+		undoRefund bool
+		keepTimer  bool
+		noLose     bool
+		speed      int
+	}
+}
+
+// startRecording begins a new recording of the level, the events of a
+// replay being watched are timed from here too. The first level played
+// waits for a key before the timer starts, recording starts then.
+func startRecording() {
+	replay.start = clock.Steps
+	replay.next = 0
+	replay.rec = atom.Replay{
+		Rate:       updateRate,
+		UndoRefund: conf.UndoRefund,
+		KeepTimer:  conf.KeepTimer,
+		NoLose:     conf.NoLose,
+		Speed:      conf.Speed,
+	}
+}
+
+func record(ev atom.ReplayEvent) {
+	if replay.watch != nil {
+		return
+	}
+	ev.Frame = clock.Steps - replay.start
+	replay.rec.Events = append(replay.rec.Events, ev)
+}
+
+func recordAction(key int) {
+	if name := atom.ActionName(key); name != "" {
+		record(atom.ReplayEvent{Action: name})
+	}
+}
+
+func saveReplay() {
+	r := &replay.rec
+	r.Date = time.Now()
+	r.Score = game.Score
+	r.Moves = game.Moves
+	r.Time = int(won.taken.Seconds() + 0.5)
+	err := conf.SaveReplay(level, r)
+	if err == nil {
+		sdl.Log("Saved replay")
+	} else {
+		sdl.Log("%v", err)
+	}
+}
+
+// watchReplay plays back the replay of the selected level with the
+// options it was recorded with.
+func watchReplay() {
+	r, err := conf.LoadReplay(level)
+	if err != nil {
+		sdl.Log("%v", err)
+		return
+	}
+	if r.Rate != updateRate {
+		sdl.Log("Replay recorded at %d updates per second instead of %d", r.Rate, updateRate)
+	}
+
+	o := &replay.options
+	o.undoRefund, o.keepTimer, o.noLose, o.speed = r.UndoRefund, r.KeepTimer, r.NoLose, r.Speed
+	swapOptions()
+
+	replay.watch = r
+	justStarted = false
+	newstate = PLAY
+}
+
+func stopReplay() {
+	swapOptions()
+	replay.watch = nil
+}
+
+// swapOptions switches between the options of the player and the ones
+// of the replay being watched.
+func swapOptions() {
+	o := &replay.options
+	o.undoRefund, conf.UndoRefund = conf.UndoRefund, o.undoRefund
+	o.keepTimer, conf.KeepTimer = conf.KeepTimer, o.keepTimer
+	o.noLose, conf.NoLose = conf.NoLose, o.noLose
+	o.speed, conf.Speed = conf.Speed, o.speed
+}
+
+// replayUpdate does what was done on this update when the replay was
+// recorded.
+func replayUpdate() {
+	r := replay.watch
+	frame := clock.Steps - replay.start
+	for ; replay.next < len(r.Events); replay.next++ {
+		ev := &r.Events[replay.next]
+		if ev.Frame > frame {
+			break
+		}
+
+		switch ev.Action {
+		case atom.ReplayPick:
+			if ev.At != nil {
+				pick(*ev.At)
+			}
+		case atom.ReplaySlide:
+			slide(ev.Dir)
+		case atom.ReplayHint:
+			if ev.Hint != nil {
+				showHint(*ev.Hint)
+			}
+		default:
+			evPlay(atom.ParseAction(ev.Action))
+		}
+	}
+}
+
+// checkReplay tells if a replay didn't end the way it did when it was
+// recorded, which happens when the level has changed since.
+func checkReplay() {
+	r := replay.watch
+	if game.Score == r.Score && game.Moves == r.Moves {
+		sdl.Log("Replay finished with a score of %d in %d moves", r.Score, r.Moves)
+		return
+	}
+	sdl.Log("Replay finished with a score of %d in %d moves, recorded %d in %d moves",
+		game.Score, game.Moves, r.Score, r.Moves)
+}
+
+// pick moves the cursor to a square and selects the atom on it.
+func pick(sq image.Point) {
+	g := game
+	record(atom.ReplayEvent{Action: atom.ReplayPick, At: &sq})
+	finishMotion()
+	g.Cursor.State = 0
+	g.Cursor.Point = sq
+	if g.Select() {
+		sfx.PlaySound(sfx.Selected, 0)
+	}
+}
+
+// slide moves the selected atom without waiting for the cursor or
+// another atom to finish moving.
+func slide(dir int) {
+	record(atom.ReplayEvent{Action: atom.ReplaySlide, Dir: dir})
+	finishMotion()
+	moveAtom(dir)
+}
+
+func showHint(m puzzle.Move) {
+	g := game
+	record(atom.ReplayEvent{Action: atom.ReplayHint, Hint: &m})
+	g.Hint = m
+	g.ShowHint = true
+	g.TakeHint()
+}
+
+// blitReplayKey tells which key plays the replay of the level.
+func blitReplayKey() {
+	key := "REPLAY"
+	for _, name := range input.Bindings.Inputs(atom.REPLAY) {
+		if !atom.IsPad(name) {
+			key = name
+			break
+		}
+	}
+	text := []byte("PRESS " + key + " TO WATCH THE REPLAY")
+	blitFont1(text, 160-font1Size(text)/2, 222)
+}
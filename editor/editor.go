@@ -3,14 +3,18 @@ package main
 import (
 	"flag"
 	"fmt"
+	"image"
 	"os"
 	"runtime"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/qeedquan/go-media/sdl"
 	"github.com/qeedquan/go-media/sdl/sdlgfx"
 
 	"github.com/qeedquan/go-atomiks/atom"
+	"github.com/qeedquan/go-atomiks/puzzle"
 )
 
 var (
@@ -18,12 +22,18 @@ var (
 	screen *atom.Display
 	gfx    *atom.GFX
 	game   *atom.Game
+	input  *atom.Input
 	view   int
 	level  int
 	char   int
 	line   int
 	item   int
 	fps    sdlgfx.FPSManager
+	status struct {
+		text string
+		ok   bool
+		end  time.Time
+	}
 )
 
 func main() {
@@ -36,8 +46,12 @@ func main() {
 	level, _ = strconv.Atoi(flag.Arg(0))
 	screen = atom.NewDisplay(conf, "Editor", false)
 	gfx = atom.LoadGFX(conf)
-	game = atom.NewGame(conf, screen, gfx, true)
+	game = atom.NewGame(conf, screen, gfx, atom.RealClock{}, true)
+	input = atom.NewInput(conf)
 	game.Load(level)
+	if game.Duration < 1 || game.Duration > puzzle.MaxDuration {
+		game.Duration = 60
+	}
 	line = 1
 
 	fps.Init()
@@ -70,21 +84,13 @@ func event() {
 			case sdl.K_ESCAPE:
 				os.Exit(0)
 			case sdl.K_LEFT:
-				if g.Cursor.X > 0 {
-					g.Cursor.X--
-				}
+				moveCursor(atom.LEFT)
 			case sdl.K_RIGHT:
-				if g.Cursor.X < 63 {
-					g.Cursor.X++
-				}
+				moveCursor(atom.RIGHT)
 			case sdl.K_UP:
-				if g.Cursor.Y > 0 {
-					g.Cursor.Y--
-				}
+				moveCursor(atom.UP)
 			case sdl.K_DOWN:
-				if g.Cursor.Y < 63 {
-					g.Cursor.Y++
-				}
+				moveCursor(atom.DOWN)
 			case sdl.K_SPACE:
 				setItem()
 			case sdl.K_INSERT:
@@ -94,22 +100,7 @@ func event() {
 					g.Solution.Set(g.Cursor.X, g.Cursor.Y, item)
 				}
 			case sdl.K_RETURN:
-				if view == 0 {
-					x := g.Cursor.X
-					y := g.Cursor.Y
-					f := &g.Field
-					switch f.Type(x, y) {
-					case atom.FREE:
-						f.Set(x, y, atom.WALL)
-					case atom.WALL:
-						f.Set(x, y, atom.ATOM)
-					case atom.ATOM:
-						f.Set(x, y, 0)
-					default:
-						f.Set(x, y, atom.FREE)
-					}
-					item = f.At(x, y)
-				}
+				cycleSquare()
 			case sdl.K_DELETE:
 				if view == 0 {
 					g.Field.Set(g.Cursor.X, g.Cursor.Y, 0)
@@ -119,11 +110,11 @@ func event() {
 			case sdl.K_TAB:
 				view = 1 - view
 			case sdl.K_KP_MINUS:
-				if g.Duration > 0 {
+				if g.Duration > 1 {
 					g.Duration--
 				}
 			case sdl.K_KP_PLUS:
-				if g.Duration < 3600 {
+				if g.Duration < puzzle.MaxDuration {
 					g.Duration++
 				}
 			case sdl.K_F1:
@@ -137,12 +128,24 @@ func event() {
 				}
 			case sdl.K_F3:
 				g.BG = (g.BG + 1) % 3
+			case sdl.K_F4:
+				cycleMatch()
+			case sdl.K_F6:
+				resize(-1, 0)
+			case sdl.K_F7:
+				resize(1, 0)
+			case sdl.K_F8:
+				resize(0, -1)
+			case sdl.K_F9:
+				resize(0, 1)
 			case sdl.K_F5:
 				err := g.Save(level)
 				if err != nil {
 					sdl.Log("Failed to save to file: %v", err)
+					setStatus("SAVE FAILED", false)
 				} else {
 					sdl.Log("Saved")
+					setStatus("SAVED", true)
 				}
 			default:
 				key := sdlk2char(ev.Sym)
@@ -154,8 +157,96 @@ func event() {
 					g.Desc[line-1][char] = byte(key)
 				}
 			}
+		default:
+			evPad(input.Event(ev))
+		}
+	}
+}
+
+// evPad lets a game controller move the cursor and change the squares,
+// the rest needs a keyboard.
+func evPad(action int) {
+	switch action {
+	case atom.UP, atom.RIGHT, atom.DOWN, atom.LEFT:
+		moveCursor(action)
+	case atom.ENTER:
+		cycleSquare()
+	case atom.SPACE:
+		setItem()
+	}
+}
+
+func moveCursor(dir int) {
+	g := game
+	c := g.Cursor.Add(puzzle.Delta(dir))
+	if 0 <= c.X && c.X < g.Size.X && 0 <= c.Y && c.Y < g.Size.Y {
+		g.Cursor.Point = c
+	}
+}
+
+// cycleSquare turns the square under the cursor on the field into the
+// next kind, going from free to wall to atom to empty.
+func cycleSquare() {
+	g := game
+	if view != 0 {
+		return
+	}
+
+	x := g.Cursor.X
+	y := g.Cursor.Y
+	f := &g.Field
+	switch f.Type(x, y) {
+	case puzzle.FREE:
+		f.Set(x, y, puzzle.WALL)
+	case puzzle.WALL:
+		f.Set(x, y, puzzle.ATOM)
+	case puzzle.ATOM:
+		f.Set(x, y, 0)
+	default:
+		f.Set(x, y, puzzle.FREE)
+	}
+	item = f.At(x, y)
+}
+
+// cycleMatch goes through the ways the level can match the solution.
+func cycleMatch() {
+	g := game
+	g.Match = (g.Match + 1) % ((puzzle.MatchShape | puzzle.MatchTurned) + 1)
+	text := "MATCH ATOMS"
+	if g.Match&puzzle.MatchShape != 0 {
+		text = "MATCH SHAPE"
+	}
+	if g.Match&puzzle.MatchTurned != 0 {
+		text += " TURNED"
+	}
+	setStatus(text, true)
+}
+
+// resize grows or shrinks the board, squares that fall off it are
+// cleared.
+func resize(dx, dy int) {
+	g := game
+	w := g.Size.X + dx
+	h := g.Size.Y + dy
+	if w < 1 || h < 1 || w > puzzle.MaxSize || h > puzzle.MaxSize {
+		return
+	}
+
+	for y := 0; y < puzzle.MaxSize; y++ {
+		for x := 0; x < puzzle.MaxSize; x++ {
+			if x >= w || y >= h {
+				g.Field.Set(x, y, 0)
+				g.Solution.Set(x, y, 0)
+			}
 		}
 	}
+	g.Size = image.Pt(w, h)
+	if g.Cursor.X >= w {
+		g.Cursor.X = w - 1
+	}
+	if g.Cursor.Y >= h {
+		g.Cursor.Y = h - 1
+	}
 }
 
 func setItem() {
@@ -168,25 +259,30 @@ func setItem() {
 	y := g.Cursor.Y
 	t := f.At(x, y)
 	switch typ := f.Type(x, y); {
-	case typ == atom.ATOM && view == 0:
-		t = (((t & atom.INDEX) + 1) % 48) | atom.ATOM
-	case typ == atom.WALL && view == 0:
-		t = (((t & atom.INDEX) + 1) % 18) | atom.WALL
-	case typ == atom.ATOM && view == 1:
-		t &= atom.INDEX
+	case typ == puzzle.ATOM && view == 0:
+		t = (((t & puzzle.INDEX) + 1) % 48) | puzzle.ATOM
+	case typ == puzzle.WALL && view == 0:
+		t = (((t & puzzle.INDEX) + 1) % 18) | puzzle.WALL
+	case typ == puzzle.ATOM && view == 1:
+		t &= puzzle.INDEX
 		if t++; t > 48 {
 			t = 0
 		} else {
-			t |= atom.ATOM
+			t |= puzzle.ATOM
 		}
-	case typ != atom.ATOM && view == 1:
-		t = atom.ATOM
+	case typ != puzzle.ATOM && view == 1:
+		t = puzzle.ATOM
 	}
 	f.Set(x, y, t)
 	item = t
+
+	if i := t & puzzle.INDEX; t&puzzle.TYPE == puzzle.ATOM && puzzle.Chemical(i) {
+		setStatus(strings.ToUpper(puzzle.ElementNames[puzzle.AtomKinds[i].Element]), true)
+	}
 }
 
 func blit() {
+	game.Follow(game.Cursor.Point)
 	screen.Clear()
 	switch view {
 	case 0:
@@ -197,6 +293,7 @@ func blit() {
 	blitTimer()
 	blitDesc()
 	blitCursor()
+	blitStatus()
 	screen.Flush()
 }
 
@@ -243,6 +340,35 @@ func blitDesc() {
 	}
 }
 
+func setStatus(text string, ok bool) {
+	status.text = text
+	status.ok = ok
+	status.end = time.Now().Add(3 * time.Second)
+}
+
+func blitStatus() {
+	if time.Now().After(status.end) {
+		return
+	}
+
+	x := 20
+	y := 20
+	r := gfx.Font3[0].Bounds()
+	w := (len(status.text) + 2) * r.Dx() * 2
+	h := (r.Dy() + 4) * 2
+	if status.ok {
+		atom.DrawRect(screen, (x-r.Dx())*2, (y-2)*2, w, h, 0, 0x80, 0, 255)
+	} else {
+		atom.DrawRect(screen, (x-r.Dx())*2, (y-2)*2, w, h, 0xc0, 0, 0, 255)
+	}
+	for _, c := range status.text {
+		if 'A' <= c && c <= 'Z' {
+			atom.DrawGFX(screen, gfx.Font3[c-'A'], x, y)
+		}
+		x += r.Dx()
+	}
+}
+
 func blitCursor() {
 	g := game
 
@@ -251,8 +377,8 @@ func blitCursor() {
 	atom.DrawGFX(screen, gfx.Cursor[g.Cursor.Type], x, y)
 
 	r := gfx.Cursor[0].Bounds()
-	x = 32 + g.Cursor.X*r.Dx()
-	y = 32 + g.Cursor.Y*r.Dy()
+	x = g.Offset.X + g.Cursor.X*r.Dx()
+	y = g.Offset.Y + g.Cursor.Y*r.Dy()
 	atom.DrawGFX(screen, gfx.Cursor[0], x, y)
 }
 
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/qeedquan/go-atomiks/puzzle"
+)
+
+var (
+	assets  = flag.String("assets", "assets", "assets directory")
+	limit   = flag.Int("limit", 50000, "maximum number of states to search for a solution")
+	build   = flag.Int("build", 1000000, "maximum number of states to spend building the molecule an atom at a time once the search gives up")
+	weight  = flag.Int("w", 3, "weight of the move estimate used by the search")
+	parlim  = flag.Int("parlimit", 300000, "maximum number of states to search for the shortest solution par is checked against")
+	nosolve = flag.Bool("nosolve", false, "don't search for a solution")
+	verbose = flag.Bool("v", false, "report levels without problems too")
+)
+
+// formulas has the formulas of the molecules levels are named after.
+var formulas = map[string]string{
+	"Ammonia":             "NH3",
+	"Butan-1-ol":          "C4H9OH",
+	"Cis-but-2-ene":       "C4H8",
+	"Dimethyl ether":      "C2H6O",
+	"Ethanal":             "C2H4O",
+	"Ethane":              "C2H6",
+	"Ethanedioic acid":    "C2H2O4",
+	"Ethanoic acid":       "C2H4O2",
+	"Ethanol":             "C2H6O",
+	"Ethene":              "C2H4",
+	"Ethyl ethanoate":     "C4H8O2",
+	"Ethyne":              "C2H2",
+	"Methanal":            "CH2O",
+	"Methane":             "CH4",
+	"Methanoic acid":      "CH2O2",
+	"Methanol":            "CH4O",
+	"2-Methylpropan-2-ol": "C4H9OH",
+	"3-Methylpentane":     "C6H14",
+	"Propanal":            "C3H6O",
+	"Propan-2-ol":         "C3H8O",
+	"Propane":             "C3H8",
+	"Propanetriol":        "C3H8O3",
+	"Propanone":           "C3H6O",
+	"Propene":             "C3H6",
+	"Propyne":             "C3H4",
+	"Trans-but-2-ene":     "C4H8",
+	"Water":               "H2O",
+}
+
+// The game has two cursors and three backgrounds.
+const (
+	minCursor = 1
+	maxCursor = 2
+	numBG     = 3
+)
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	err := puzzle.LoadAtomKinds(filepath.Join(*assets, puzzle.AtomsFile))
+	if !os.IsNotExist(err) {
+		ck(err)
+	}
+
+	files := flag.Args()
+	if len(files) == 0 {
+		for _, pat := range []string{"lev*.dat", "lev*" + puzzle.TextExt} {
+			m, err := filepath.Glob(filepath.Join(*assets, "lev", pat))
+			ck(err)
+			files = append(files, m...)
+		}
+		sort.Strings(files)
+	}
+
+	status := 0
+	for _, name := range files {
+		problems, err := check(name)
+		if err != nil {
+			fmt.Println(err)
+			status = 1
+			continue
+		}
+		for _, p := range problems {
+			fmt.Printf("%s: %s\n", name, p)
+		}
+		if len(problems) > 0 {
+			status = 1
+		} else if *verbose {
+			fmt.Printf("%s: ok\n", name)
+		}
+	}
+	os.Exit(status)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: levcheck [options] [level ...]")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func check(name string) ([]string, error) {
+	l, err := puzzle.LoadLevel(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []string
+	report := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	// atoms that can fill the same squares of the solution are counted
+	// together, which is all of them when any atom will do
+	count := make(map[int]int)
+	kind := func(v int) int {
+		if puzzle.Matches(v, puzzle.ATOM, l.Match) {
+			return puzzle.ATOM
+		}
+		return v
+	}
+	for y := 0; y < l.Height; y++ {
+		for x := 0; x < l.Width; x++ {
+			v := l.Field.At(x, y)
+			index := v & puzzle.INDEX
+			switch v & puzzle.TYPE {
+			case puzzle.WALL:
+				if index >= puzzle.NumWalls {
+					report("wall index %d at (%d,%d) out of range", index, x, y)
+				}
+			case puzzle.ATOM:
+				if index >= puzzle.NumAtoms {
+					report("atom index %d at (%d,%d) out of range", index, x, y)
+				}
+				count[kind(v)]++
+			}
+		}
+	}
+
+	for y := 0; y < l.Height; y++ {
+		for x := 0; x < l.Width; x++ {
+			v := l.Solution.At(x, y)
+			switch v & puzzle.TYPE {
+			case 0:
+			case puzzle.ATOM:
+				count[kind(v)]--
+			default:
+				report("solution square at (%d,%d) is not an atom", x, y)
+			}
+		}
+	}
+
+	for _, p := range l.Solution.LooseBonds() {
+		report("solution atom %d at (%d,%d) has a bond that isn't joined", l.Solution.Index(p.X, p.Y), p.X, p.Y)
+	}
+
+	if l.Formula != "" {
+		if _, err := puzzle.ParseFormula(l.Formula); err != nil {
+			report("%v", err)
+		} else if f := l.Solution.Formula(); f != "" && !l.Solution.HasFormula(l.Formula) {
+			report("formula %s doesn't have the atoms of the solution %s", l.Formula, f)
+		}
+	}
+
+	if m, ok := formulas[l.Molecule]; ok {
+		if f := l.Solution.Formula(); f != "" && !l.Solution.HasFormula(m) {
+			report("molecule %s is %s but the solution is %s", l.Molecule, m, f)
+		}
+	}
+
+	var atoms []int
+	for v := range count {
+		atoms = append(atoms, v)
+	}
+	sort.Ints(atoms)
+	matched := true
+	for _, v := range atoms {
+		what := fmt.Sprintf("of atom %d", v&puzzle.INDEX)
+		if l.Match&puzzle.MatchShape != 0 {
+			what = "atoms"
+		}
+		switch n := count[v]; {
+		case n < 0:
+			report("solution has %d more %s than the field", -n, what)
+			matched = false
+		case n > 0:
+			report("field has %d more %s than the solution", n, what)
+			matched = false
+		}
+	}
+
+	if l.Duration < 1 || l.Duration > puzzle.MaxDuration {
+		report("duration %d is outside 1 to %d seconds", l.Duration, puzzle.MaxDuration)
+	}
+	if l.Cursor < minCursor || l.Cursor > maxCursor {
+		report("cursor type %d out of range", l.Cursor)
+	}
+	if l.BG < 0 || l.BG >= numBG {
+		report("background %d out of range", l.BG)
+	}
+	for i := range l.Desc {
+		for _, c := range l.Desc[i] {
+			if c == 0 {
+				break
+			}
+			if !printable(c) {
+				report("description line %d has character %q that can't be drawn", i+1, c)
+			}
+		}
+	}
+
+	if matched && !*nosolve {
+		var p puzzle.Puzzle
+		p.Init(l)
+
+		// A par has to be the shortest solution, which settles whether
+		// there is one too.
+		known := false
+		if l.Par > 0 {
+			moves, err := puzzle.Solve(&p, *parlim)
+			switch err {
+			case nil:
+				if len(moves) != l.Par {
+					report("par %d but the shortest solution has %d moves", l.Par, len(moves))
+				}
+				known = true
+			case puzzle.ErrLimit:
+				report("par %d can't be checked, no shortest solution found within the search limit", l.Par)
+			default:
+				report("par %d but %v", l.Par, err)
+				known = true
+			}
+		}
+
+		if !known {
+			s := puzzle.Solver{Limit: *limit, Weight: *weight, Build: *build}
+			moves, _, err := s.Solve(&p)
+			switch {
+			case err == nil:
+				if l.Par > len(moves) {
+					report("par %d but a solution has %d moves", l.Par, len(moves))
+				}
+			case err == puzzle.ErrLimit:
+				report("no solution found within the search limit")
+			default:
+				report("%v", err)
+			}
+		}
+	}
+
+	return problems, nil
+}
+
+// printable reports whether the description font has a glyph for c, it
+// only has letters and digits, spaces are drawn as a gap.
+func printable(c byte) bool {
+	switch {
+	case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == ' ':
+		return true
+	}
+	return false
+}
+
+func ck(err error) {
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "levcheck:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/qeedquan/go-atomiks/puzzle"
+)
+
+var (
+	outdir = flag.String("o", "", "output directory, files are rewritten in place if empty")
+	legacy = flag.Bool("legacy", false, "write the legacy format")
+	text   = flag.Bool("text", false, "write the text format")
+	author = flag.String("author", "", "set the author")
+	par    = flag.Bool("par", false, "work out par with the solver, it is cleared if no shortest solution is found")
+	limit  = flag.Int("limit", 300000, "maximum number of states to search for par")
+)
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() < 1 {
+		usage()
+	}
+
+	status := 0
+	for _, name := range flag.Args() {
+		if ek(convert(name)) {
+			status = 1
+		}
+	}
+	os.Exit(status)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: levconv [options] level ...")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func convert(name string) error {
+	l, err := puzzle.LoadLevel(name)
+	if err != nil {
+		return err
+	}
+
+	if l.Title == "" {
+		l.Title = title(l.Desc)
+	}
+	if *author != "" {
+		l.Author = *author
+	}
+	if *par {
+		var p puzzle.Puzzle
+		p.Init(l)
+		moves, err := puzzle.Solve(&p, *limit)
+		if err == nil {
+			l.Par = len(moves)
+		} else {
+			l.Par = 0
+			fmt.Fprintf(os.Stderr, "levconv: %s: par: %v\n", name, err)
+		}
+	}
+
+	w := new(bytes.Buffer)
+	ext := ".dat"
+	switch {
+	case *text:
+		err = l.WriteText(w)
+		ext = puzzle.TextExt
+	case *legacy:
+		err = l.WriteLegacy(w)
+	default:
+		err = l.Write(w)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+
+	name = strings.TrimSuffix(name, filepath.Ext(name)) + ext
+	if *outdir != "" {
+		name = filepath.Join(*outdir, filepath.Base(name))
+	}
+	return ioutil.WriteFile(name, w.Bytes(), 0644)
+}
+
+// title makes a level title out of the two description lines.
+func title(desc [2][15]byte) string {
+	var words []string
+	for _, line := range desc {
+		n := bytes.IndexByte(line[:], 0)
+		if n < 0 {
+			n = len(line)
+		}
+		if n > 0 {
+			words = append(words, string(line[:1])+strings.ToLower(string(line[1:n])))
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+func ek(err error) bool {
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "levconv:", err)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/qeedquan/go-atomiks/puzzle"
+)
+
+var (
+	assets     = flag.String("assets", "assets", "assets directory to read the atoms file from")
+	outdir     = flag.String("o", ".", "directory to write the levels to")
+	count      = flag.Int("n", 10, "number of levels to generate")
+	start      = flag.Int("start", 1, "number of the first level")
+	seed       = flag.Int64("seed", 0, "random seed, 0 uses the time")
+	difficulty = flag.String("d", "medium", "difficulty: "+difficulties())
+	limit      = flag.Int("limit", 50000, "maximum number of states to search for a solution")
+	pack       = flag.String("pack", "", "write a pack manifest with this name listing the levels")
+)
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	err := puzzle.LoadAtomKinds(filepath.Join(*assets, puzzle.AtomsFile))
+	if !os.IsNotExist(err) {
+		ck(err)
+	}
+
+	d, ok := puzzle.FindDifficulty(*difficulty)
+	if !ok {
+		ck(fmt.Errorf("unknown difficulty %q", *difficulty))
+	}
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	ck(os.MkdirAll(*outdir, 0755))
+
+	g := puzzle.NewGenerator(d, *seed)
+	g.Limit = *limit
+
+	var names []string
+	for i := 0; i < *count; i++ {
+		l, err := g.Generate()
+		ck(err)
+
+		n := *start + i
+		l.Title = fmt.Sprintf("Generated %d", n)
+		l.Author = "levgen"
+		name := fmt.Sprintf("lev%04d", n)
+		ck(l.Save(filepath.Join(*outdir, name+".dat")))
+		names = append(names, name)
+		fmt.Printf("%s.dat: par %d\n", name, l.Par)
+	}
+
+	if *pack != "" {
+		ck(writeManifest(filepath.Join(*outdir, "pack.txt"), names))
+	}
+	fmt.Printf("seed %d\n", *seed)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: levgen [options]")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func difficulties() string {
+	var s []string
+	for _, d := range puzzle.Difficulties {
+		s = append(s, d.Name)
+	}
+	return strings.Join(s, ", ")
+}
+
+func writeManifest(name string, levels []string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "name %q\n", *pack)
+	fmt.Fprintf(&b, "author %q\n", "levgen")
+	for _, l := range levels {
+		fmt.Fprintf(&b, "level %s\n", l)
+	}
+	return ioutil.WriteFile(name, []byte(b.String()), 0644)
+}
+
+func ck(err error) {
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "levgen:", err)
+		os.Exit(1)
+	}
+}
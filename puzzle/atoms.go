@@ -0,0 +1,40 @@
+// Code generated by mkatoms.go from assets/atoms.txt; DO NOT EDIT.
+
+package puzzle
+
+// AtomKinds describes the atom pictures as the atoms file in the assets
+// does, loading the file replaces it.
+var AtomKinds = [NumAtoms]AtomKind{
+	0:  {"H", Bonds{South: 1}},
+	1:  {"H", Bonds{SouthWest: 1}},
+	2:  {"H", Bonds{West: 1}},
+	3:  {"H", Bonds{NorthWest: 1}},
+	4:  {"H", Bonds{North: 1}},
+	5:  {"H", Bonds{NorthEast: 1}},
+	6:  {"H", Bonds{East: 1}},
+	7:  {"H", Bonds{SouthEast: 1}},
+	8:  {"O", Bonds{East: 1, West: 1}},
+	9:  {"O", Bonds{North: 1, South: 1}},
+	10: {"O", Bonds{West: 2}},
+	11: {"O", Bonds{North: 2}},
+	12: {"O", Bonds{South: 2}},
+	13: {"O", Bonds{East: 2}},
+	14: {"C", Bonds{North: 1, East: 1, South: 1, West: 1}},
+	15: {"C", Bonds{NorthEast: 1, SouthEast: 1, West: 2}},
+	16: {"C", Bonds{East: 2, SouthWest: 1, NorthWest: 1}},
+	17: {"C", Bonds{North: 1, East: 2, West: 1}},
+	18: {"C", Bonds{North: 2, East: 1, West: 1}},
+	19: {"C", Bonds{East: 1, South: 2, West: 1}},
+	20: {"C", Bonds{NorthEast: 1, SouthEast: 1, SouthWest: 1, NorthWest: 1}},
+	21: {"C", Bonds{North: 1, NorthEast: 1, South: 1, NorthWest: 1}},
+	22: {"C", Bonds{North: 1, South: 1, West: 1}},
+	23: {"C", Bonds{North: 1, East: 1, South: 1}},
+	24: {"C", Bonds{East: 2, South: 1, West: 1}},
+	25: {"C", Bonds{East: 1, South: 1, West: 2}},
+	26: {"C", Bonds{North: 1, East: 2, South: 1}},
+	27: {"C", Bonds{East: 3, West: 1}},
+	28: {"C", Bonds{North: 1, East: 1, West: 3}},
+	29: {"F", Bonds{North: 1}},
+	30: {"F", Bonds{South: 1}},
+	31: {"N", Bonds{NorthEast: 1, SouthEast: 1, West: 1}},
+}
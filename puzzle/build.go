@@ -0,0 +1,165 @@
+package puzzle
+
+import (
+	"image"
+	"sort"
+)
+
+// buildStep is how many states the search for the next atom of the
+// molecule starts with, it grows up to buildSteps times that before
+// going back on the atoms already placed.
+const (
+	buildStep  = 5000
+	buildSteps = 64
+)
+
+// goal is an atom the molecule needs on a square of the field.
+type goal struct {
+	at    image.Point
+	value int
+}
+
+// builder fills the squares of the molecule the way a player does, one
+// atom at a time, keeping the atoms already in place.
+type builder struct {
+	solver *Solver
+	match  int
+	goals  []goal
+	done   []bool
+	used   int
+}
+
+// build tries the places the molecule fits on the field from the closest,
+// it can't tell that a puzzle has no solution.
+func (v *Solver) build(p *Puzzle) ([]Move, error) {
+	s := &search{best: make(map[string]int)}
+	start := s.init(p)
+	for i := range s.group {
+		s.nearest(start, i)
+	}
+
+	type place struct {
+		*placement
+		h int
+	}
+	var places []place
+	all := s.places
+	for i := range all {
+		s.places = all[i : i+1]
+		if h := s.estimate([]byte(start)); h >= 0 {
+			places = append(places, place{&all[i], h})
+		}
+	}
+	if len(places) == 0 {
+		return nil, ErrUnsolvable
+	}
+	sort.SliceStable(places, func(i, j int) bool {
+		return places[i].h < places[j].h
+	})
+
+	b := &builder{solver: v, match: p.Match &^ MatchTurned}
+	for _, pl := range places {
+		b.goals = b.goals[:0]
+		for i, g := range s.group {
+			for _, t := range pl.targets[i] {
+				b.goals = append(b.goals, goal{t.Add(pl.at), s.atoms[g[0]]})
+			}
+		}
+		b.done = make([]bool, len(b.goals))
+		if moves, ok := b.fill(p.Field); ok {
+			return moves, nil
+		}
+		if b.used >= v.Build {
+			break
+		}
+	}
+	return nil, ErrLimit
+}
+
+// fill places the atoms left from the field f and returns the moves it
+// took. Squares next to walls and atoms already placed go first, since
+// an atom needs something to stop against.
+func (b *builder) fill(f Grid) ([]Move, bool) {
+	var order []int
+	score := make([]int, len(b.goals))
+	for i, g := range b.goals {
+		if b.done[i] {
+			continue
+		}
+		for dir := UP; dir <= LEFT; dir++ {
+			if b.blocked(&f, g.at.Add(Delta(dir))) {
+				score[i]++
+			}
+		}
+		if f.At(g.at.X, g.at.Y) == g.value {
+			score[i] += 5
+		}
+		order = append(order, i)
+	}
+	if len(order) == 0 {
+		return nil, true
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return score[order[i]] > score[order[j]]
+	})
+
+	failed := make([]bool, len(b.goals))
+	for limit := buildStep; limit <= buildSteps*buildStep; limit *= 4 {
+		for _, i := range order {
+			if failed[i] {
+				continue
+			}
+			if b.used >= b.solver.Build {
+				return nil, false
+			}
+
+			moves, n, err := b.solver.run(b.puzzle(&f, i), limit)
+			b.used += n
+			if err != nil {
+				failed[i] = err == ErrUnsolvable
+				continue
+			}
+			failed[i] = true
+
+			g := f
+			for _, m := range moves {
+				g.Slide(m.From.X, m.From.Y, m.Dir)
+			}
+			b.done[i] = true
+			rest, ok := b.fill(g)
+			b.done[i] = false
+			if ok {
+				return append(moves, rest...), true
+			}
+		}
+	}
+	return nil, false
+}
+
+// puzzle returns the field f with a solution of the atoms placed so far
+// and the atom of goal i.
+func (b *builder) puzzle(f *Grid, i int) *Puzzle {
+	p := &Puzzle{Field: *f, Match: b.match}
+	p.Solution.Width = f.Width
+	p.Solution.Height = f.Height
+	for j, g := range b.goals {
+		if b.done[j] || j == i {
+			p.Solution.Set(g.at.X, g.at.Y, g.value)
+		}
+	}
+	return p
+}
+
+// blocked reports whether an atom sliding into p would stop before it
+// once the atoms placed so far are there.
+func (b *builder) blocked(f *Grid, p image.Point) bool {
+	if !p.In(image.Rect(0, 0, f.Width, f.Height)) || f.Type(p.X, p.Y) == WALL || f.At(p.X, p.Y) == 0 {
+		return true
+	}
+	for j, g := range b.goals {
+		if b.done[j] && g.at == p {
+			return true
+		}
+	}
+	return false
+}
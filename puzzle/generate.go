@@ -0,0 +1,382 @@
+package puzzle
+
+import (
+	"errors"
+	"image"
+	"math/rand"
+	"strings"
+)
+
+var ErrGenerate = errors.New("puzzle: could not generate a level")
+
+// A Difficulty bounds the size of the molecule and the number of moves
+// it takes to build it.
+type Difficulty struct {
+	Name     string
+	MinAtoms int
+	MaxAtoms int
+	MinMoves int
+	MaxMoves int
+}
+
+var Difficulties = []Difficulty{
+	{"easy", 3, 5, 2, 6},
+	{"medium", 5, 8, 6, 10},
+	{"hard", 7, 12, 10, 40},
+}
+
+// FindDifficulty looks up a difficulty by name.
+func FindDifficulty(name string) (Difficulty, bool) {
+	for _, d := range Difficulties {
+		if strings.EqualFold(d.Name, name) {
+			return d, true
+		}
+	}
+	return Difficulty{}, false
+}
+
+// Generator makes levels by growing a molecule out of the atoms whose
+// bonds make sense, placing it built in an arena and taking it apart by
+// sliding its atoms backwards. Every slide taken back can be made
+// forward, so the level can always be solved, the solver then finds
+// the shortest way which is used to rate it.
+type Generator struct {
+	Difficulty
+
+	// Size is the width and height of the arena including its walls and
+	// Molecule the largest a molecule can be.
+	Size     image.Point
+	Molecule image.Point
+
+	// Walls is the fraction of the inside of the arena taken by walls.
+	Walls float64
+
+	// Limit is the maximum number of states the solver expands for each
+	// attempt and Tries the number of attempts made.
+	Limit int
+	Tries int
+
+	rand *rand.Rand
+}
+
+func NewGenerator(d Difficulty, seed int64) *Generator {
+	return &Generator{
+		Difficulty: d,
+		Size:       image.Pt(LevelSize-2, LevelSize-4),
+		Molecule:   image.Pt(6, 5),
+		Walls:      0.12,
+		Limit:      50000,
+		Tries:      200,
+		rand:       rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Generate returns a new level with its par set to the moves the solver
+// needed.
+func (g *Generator) Generate() (*Level, error) {
+	for i := 0; i < g.Tries; i++ {
+		sol, ok := g.Grow()
+		if !ok {
+			continue
+		}
+		l, ok := g.arena(&sol)
+		if !ok {
+			continue
+		}
+
+		var p Puzzle
+		p.Init(l)
+		moves, err := Solve(&p, g.Limit)
+		if err != nil || len(moves) < g.MinMoves || len(moves) > g.MaxMoves {
+			continue
+		}
+		l.Par = len(moves)
+		l.Duration = duration(l.Par)
+		l.Cursor = 1
+		l.BG = g.rand.Intn(3)
+		copy(l.Desc[0][:], "GENERATED")
+		copy(l.Desc[1][:], strings.ToUpper(g.Name))
+		return l, nil
+	}
+	return nil, ErrGenerate
+}
+
+// duration gives ten seconds a move on top of a minute, rounded up to a
+// quarter of a minute like the original levels, up to MaxDuration.
+func duration(moves int) int {
+	t := (60 + 10*moves + 14) / 15 * 15
+	if t > MaxDuration {
+		t = MaxDuration
+	}
+	return t
+}
+
+// Grow builds a molecule of MinAtoms to MaxAtoms atoms that fits in
+// Molecule, every bond of it joining two atoms.
+func (g *Generator) Grow() (Grid, bool) {
+	var m Grid
+	m.Width, m.Height = g.Molecule.X, g.Molecule.Y
+
+	sq := image.Pt(g.rand.Intn(m.Width), g.rand.Intn(m.Height))
+	var first []int
+	for i := range AtomKinds {
+		if Sensible(i) && AtomKinds[i].Bonds.Total() > 1 && fits(&m, sq, i) {
+			first = append(first, i)
+		}
+	}
+	if len(first) == 0 {
+		return Grid{}, false
+	}
+	m.Set(sq.X, sq.Y, ATOM|first[g.rand.Intn(len(first))])
+
+	target := g.MinAtoms + g.rand.Intn(g.MaxAtoms-g.MinAtoms+1)
+	budget := 2000
+	if !g.grow(&m, target, &budget) {
+		return Grid{}, false
+	}
+	m.Fit()
+	g.trim(&m)
+	return m, true
+}
+
+// grow fills the squares bonds point to, backtracking when a square
+// can't be filled. Once there are enough atoms only atoms that don't
+// need any more squares filled are used.
+func (g *Generator) grow(m *Grid, target int, budget *int) bool {
+	sq, ok := stub(m)
+	if !ok {
+		return count(m) >= g.MinAtoms
+	}
+	if *budget--; *budget < 0 {
+		return false
+	}
+
+	atoms, pending := count(m), len(stubs(m))
+	var grows, closes []int
+	for i := range AtomKinds {
+		if !Sensible(i) || !fits(m, sq, i) {
+			continue
+		}
+		m.Set(sq.X, sq.Y, ATOM|i)
+		n := atoms + 1 + len(stubs(m))
+		m.Set(sq.X, sq.Y, 0)
+		switch {
+		case n > g.MaxAtoms:
+		case n > atoms+pending:
+			grows = append(grows, i)
+		default:
+			closes = append(closes, i)
+		}
+	}
+	g.rand.Shuffle(len(grows), func(i, j int) { grows[i], grows[j] = grows[j], grows[i] })
+	g.rand.Shuffle(len(closes), func(i, j int) { closes[i], closes[j] = closes[j], closes[i] })
+
+	var try []int
+	if atoms+pending < target {
+		try = append(grows, closes...)
+	} else {
+		try = append(closes, grows...)
+	}
+	for _, i := range try {
+		m.Set(sq.X, sq.Y, ATOM|i)
+		if g.grow(m, target, budget) {
+			return true
+		}
+		m.Set(sq.X, sq.Y, 0)
+	}
+	return false
+}
+
+// stubs returns the empty squares of a molecule that bonds point to.
+func stubs(m *Grid) []image.Point {
+	var s []image.Point
+	for y := 0; y < m.Height; y++ {
+		for x := 0; x < m.Width; x++ {
+			if m.Type(x, y) != 0 {
+				continue
+			}
+			for d := range compass {
+				if bond(m, image.Pt(x, y).Add(compass[d]), Opposite(d)) > 0 {
+					s = append(s, image.Pt(x, y))
+					break
+				}
+			}
+		}
+	}
+	return s
+}
+
+// stub returns the first square a bond points to, bonds pointing out of
+// the molecule are caught by fits.
+func stub(m *Grid) (image.Point, bool) {
+	s := stubs(m)
+	if len(s) == 0 {
+		return image.ZP, false
+	}
+	return s[0], true
+}
+
+// fits reports whether an atom can go on an empty square of a molecule,
+// its bonds have to match the bonds of the atoms around it, stay inside
+// the molecule and not cross another bond.
+func fits(m *Grid, sq image.Point, index int) bool {
+	b := &AtomKinds[index].Bonds
+	for d, v := range compass {
+		p := sq.Add(v)
+		inside := p.X >= 0 && p.Y >= 0 && p.X < m.Width && p.Y < m.Height
+		if !inside {
+			if b[d] > 0 {
+				return false
+			}
+			continue
+		}
+		if m.Type(p.X, p.Y) == ATOM && bond(m, p, Opposite(d)) != b[d] {
+			return false
+		}
+		if b[d] > 0 && d&1 == 1 {
+			// The other diagonal of the same four squares.
+			a := image.Pt(p.X, sq.Y)
+			if bond(m, a, dirOf(image.Pt(sq.X, p.Y).Sub(a))) > 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func dirOf(d image.Point) int {
+	for i, v := range compass {
+		if v == d {
+			return i
+		}
+	}
+	return -1
+}
+
+func count(m *Grid) int {
+	n := 0
+	for y := 0; y < m.Height; y++ {
+		for x := 0; x < m.Width; x++ {
+			if m.Type(x, y) == ATOM {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// trim moves a molecule to the top left corner of its grid.
+func (g *Generator) trim(m *Grid) {
+	min := image.Pt(m.Width, m.Height)
+	for y := 0; y < m.Height; y++ {
+		for x := 0; x < m.Width; x++ {
+			if m.Type(x, y) == ATOM {
+				if x < min.X {
+					min.X = x
+				}
+				if y < min.Y {
+					min.Y = y
+				}
+			}
+		}
+	}
+	var t Grid
+	for y := min.Y; y < m.Height; y++ {
+		for x := min.X; x < m.Width; x++ {
+			t.Set(x-min.X, y-min.Y, m.At(x, y))
+		}
+	}
+	t.Fit()
+	*m = t
+}
+
+// walls are the wall pictures the original levels are mostly built with.
+var walls = []int{1, 2, 3, 5, 6, 8, 9, 10, 11, 12}
+
+// arena walls in a field, puts the molecule in it built and takes it
+// apart by sliding atoms backwards from where they stand.
+func (g *Generator) arena(sol *Grid) (*Level, bool) {
+	l := &Level{Width: g.Size.X, Height: g.Size.Y, Solution: *sol}
+	f := &l.Field
+	border := WALL | walls[g.rand.Intn(len(walls))]
+	inner := WALL | walls[g.rand.Intn(len(walls))]
+	for y := 0; y < l.Height; y++ {
+		for x := 0; x < l.Width; x++ {
+			switch {
+			case x == 0 || y == 0 || x == l.Width-1 || y == l.Height-1:
+				f.Set(x, y, border)
+			case g.rand.Float64() < g.Walls:
+				f.Set(x, y, inner)
+			default:
+				f.Set(x, y, FREE)
+			}
+		}
+	}
+
+	var spots []image.Point
+	for y := 1; y+sol.Height < l.Height; y++ {
+		for x := 1; x+sol.Width < l.Width; x++ {
+			if g.room(f, sol, x, y) {
+				spots = append(spots, image.Pt(x, y))
+			}
+		}
+	}
+	if len(spots) == 0 {
+		return nil, false
+	}
+	at := spots[g.rand.Intn(len(spots))]
+	var atoms []image.Point
+	for y := 0; y < sol.Height; y++ {
+		for x := 0; x < sol.Width; x++ {
+			if sol.Type(x, y) == ATOM {
+				p := at.Add(image.Pt(x, y))
+				f.Set(p.X, p.Y, sol.At(x, y))
+				atoms = append(atoms, p)
+			}
+		}
+	}
+
+	for n := 4 * g.MaxMoves; n > 0; n-- {
+		i := g.rand.Intn(len(atoms))
+		if p, ok := g.pull(f, atoms[i]); ok {
+			atoms[i] = p
+		}
+	}
+	f.Fit()
+	return l, true
+}
+
+// room reports whether the squares under the atoms of the molecule are
+// free when placed at x, y.
+func (g *Generator) room(f, sol *Grid, x, y int) bool {
+	for yy := 0; yy < sol.Height; yy++ {
+		for xx := 0; xx < sol.Width; xx++ {
+			if sol.Type(xx, yy) == ATOM && f.Type(x+xx, y+yy) != FREE {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// pull takes back a slide that could have ended at p, the atom must be
+// stopped by something on the far side and is put back anywhere along
+// the free squares behind it.
+func (g *Generator) pull(f *Grid, p image.Point) (image.Point, bool) {
+	dir := UP + g.rand.Intn(4)
+	d := Delta(dir)
+	if b := p.Add(d); f.Type(b.X, b.Y) == FREE {
+		return p, false
+	}
+	n := 0
+	for q := p.Sub(d); f.Type(q.X, q.Y) == FREE; q = q.Sub(d) {
+		n++
+	}
+	if n == 0 {
+		return p, false
+	}
+	q := p.Sub(d.Mul(1 + g.rand.Intn(n)))
+	f.Set(q.X, q.Y, f.At(p.X, p.Y))
+	f.Set(p.X, p.Y, FREE)
+	return q, true
+}
@@ -0,0 +1,377 @@
+package puzzle
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Levels are stored either in the legacy format inherited from the
+// original game, a fixed 16x16 field and solution followed by the
+// duration, description, cursor type and background, or in a versioned
+// format made of a magic string and version followed by tagged sections
+// that readers skip if they do not know them.
+const (
+	LevelMagic   = "ATMK"
+	LevelVersion = 1
+	LevelSize    = 16
+)
+
+// MaxDuration is the longest time in seconds a level can have, the game
+// shows a single digit of minutes.
+const MaxDuration = 9*60 + 59
+
+const legacySize = 2*LevelSize*LevelSize + 2 + 2*15 + 2
+
+var (
+	ErrShortLevel   = errors.New("puzzle: level file too short")
+	ErrLevelVersion = errors.New("puzzle: unsupported level version")
+	ErrLevelFormat  = errors.New("puzzle: malformed level file")
+	ErrLegacySize   = errors.New("puzzle: board too big for the legacy level format")
+)
+
+type Level struct {
+	Field    Grid
+	Solution Grid
+	Width    int
+	Height   int
+	Duration int
+	Match    int
+	Desc     [2][15]byte
+	Cursor   int
+	BG       int
+	Par      int
+	Title    string
+	Author   string
+	Molecule string
+	Formula  string
+	Blurb    string
+}
+
+// LoadLevel loads a level file, text levels are told apart by their
+// extension.
+func LoadLevel(name string) (*Level, error) {
+	fd, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
+	var l *Level
+	if IsText(name) {
+		l, err = ReadText(fd)
+	} else {
+		l, err = ReadLevel(fd)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return l, nil
+}
+
+// ReadLevel reads a level in either format.
+func ReadLevel(r io.Reader) (*Level, error) {
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &Level{Width: LevelSize, Height: LevelSize}
+	if bytes.HasPrefix(buf, []byte(LevelMagic)) {
+		err = l.decode(buf[len(LevelMagic):])
+	} else {
+		err = l.decodeLegacy(buf)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	l.Field.Fit()
+	l.Solution.Fit()
+	return l, nil
+}
+
+func (l *Level) decodeLegacy(buf []byte) error {
+	if len(buf) < legacySize {
+		return ErrShortLevel
+	}
+
+	r := bytes.NewReader(buf)
+	for y := 0; y < LevelSize; y++ {
+		for x := 0; x < LevelSize; x++ {
+			l.Field.Set(x, y, readByte(r))
+		}
+	}
+
+	for y := 0; y < LevelSize; y++ {
+		for x := 0; x < LevelSize; x++ {
+			l.Solution.Set(x, y, readByte(r))
+		}
+	}
+
+	l.Duration = readShort(r)
+
+	for i := range l.Desc {
+		for x := range l.Desc[i] {
+			l.Desc[i][x] = byte(readByte(r))
+		}
+	}
+
+	l.Cursor = readByte(r)
+	l.BG = readByte(r)
+	return nil
+}
+
+func (l *Level) decode(buf []byte) error {
+	if len(buf) < 2 {
+		return ErrShortLevel
+	}
+	if v := binary.BigEndian.Uint16(buf); v < 1 || v > LevelVersion {
+		return ErrLevelVersion
+	}
+	buf = buf[2:]
+
+	for len(buf) > 0 {
+		if len(buf) < 8 {
+			return ErrShortLevel
+		}
+		tag := string(buf[:4])
+		n := binary.BigEndian.Uint32(buf[4:])
+		buf = buf[8:]
+		if uint32(len(buf)) < n {
+			return ErrShortLevel
+		}
+		data := buf[:n]
+		buf = buf[n:]
+
+		var err error
+		switch tag {
+		case "SIZE":
+			if len(data) < 2 {
+				return ErrLevelFormat
+			}
+			l.Width, l.Height = int(data[0]), int(data[1])
+			if !l.Field.Inside(l.Width-1, l.Height-1) {
+				return ErrLevelFormat
+			}
+		case "FELD":
+			err = decodeGrid(&l.Field, data)
+		case "SOLN":
+			err = decodeGrid(&l.Solution, data)
+		case "TIME":
+			l.Duration, err = decodeShort(data)
+		case "DESC":
+			if len(data) < 2*15 {
+				return ErrLevelFormat
+			}
+			copy(l.Desc[0][:], data)
+			copy(l.Desc[1][:], data[15:])
+		case "CURS":
+			l.Cursor, err = decodeByte(data)
+		case "BACK":
+			l.BG, err = decodeByte(data)
+		case "PAR ":
+			l.Par, err = decodeShort(data)
+		case "MTCH":
+			l.Match, err = decodeByte(data)
+		case "NAME":
+			l.Title = string(data)
+		case "AUTH":
+			l.Author = string(data)
+		case "MOLE":
+			l.Molecule = string(data)
+		case "FORM":
+			l.Formula = string(data)
+		case "BLRB":
+			l.Blurb = string(data)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decodeGrid(g *Grid, data []byte) error {
+	if len(data) < 2 {
+		return ErrLevelFormat
+	}
+	w, h := int(data[0]), int(data[1])
+	if len(data) < 2+w*h || (w*h > 0 && !g.Inside(w-1, h-1)) {
+		return ErrLevelFormat
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			g.Set(x, y, int(data[2+y*w+x]))
+		}
+	}
+	return nil
+}
+
+func decodeByte(data []byte) (int, error) {
+	if len(data) < 1 {
+		return 0, ErrLevelFormat
+	}
+	return int(data[0]), nil
+}
+
+func decodeShort(data []byte) (int, error) {
+	if len(data) < 2 {
+		return 0, ErrLevelFormat
+	}
+	return int(binary.BigEndian.Uint16(data)), nil
+}
+
+// Write writes the level in the versioned format.
+func (l *Level) Write(wr io.Writer) error {
+	width, height := l.Width, l.Height
+	if width == 0 || height == 0 {
+		width, height = LevelSize, LevelSize
+	}
+	sol := l.Solution
+	sol.Fit()
+
+	w := bufio.NewWriter(wr)
+	w.WriteString(LevelMagic)
+	binary.Write(w, binary.BigEndian, uint16(LevelVersion))
+
+	section(w, "SIZE", []byte{byte(width), byte(height)})
+	section(w, "FELD", encodeGrid(&l.Field, width, height))
+	section(w, "SOLN", encodeGrid(&sol, sol.Width, sol.Height))
+	section(w, "TIME", []byte{byte(l.Duration >> 8), byte(l.Duration)})
+	section(w, "DESC", append(l.Desc[0][:], l.Desc[1][:]...))
+	section(w, "CURS", []byte{byte(l.Cursor)})
+	section(w, "BACK", []byte{byte(l.BG)})
+	if l.Par > 0 {
+		section(w, "PAR ", []byte{byte(l.Par >> 8), byte(l.Par)})
+	}
+	if l.Match != 0 {
+		section(w, "MTCH", []byte{byte(l.Match)})
+	}
+	if l.Title != "" {
+		section(w, "NAME", []byte(l.Title))
+	}
+	if l.Author != "" {
+		section(w, "AUTH", []byte(l.Author))
+	}
+	if l.Molecule != "" {
+		section(w, "MOLE", []byte(l.Molecule))
+	}
+	if l.Formula != "" {
+		section(w, "FORM", []byte(l.Formula))
+	}
+	if l.Blurb != "" {
+		section(w, "BLRB", []byte(l.Blurb))
+	}
+
+	return w.Flush()
+}
+
+// Save saves the level to a file, in the text format if the file
+// extension asks for it. The level is written to a temporary file first
+// that then replaces the old one, so a failed save leaves it untouched.
+func (l *Level) Save(name string) error {
+	fd, err := ioutil.TempFile(filepath.Dir(name), "."+filepath.Base(name))
+	if err != nil {
+		return err
+	}
+
+	if IsText(name) {
+		err = l.WriteText(fd)
+	} else {
+		err = l.Write(fd)
+	}
+	if err == nil {
+		err = fd.Chmod(0644)
+	}
+	xerr := fd.Close()
+	if err == nil {
+		err = xerr
+	}
+	if err == nil {
+		err = os.Rename(fd.Name(), name)
+	}
+	if err != nil {
+		os.Remove(fd.Name())
+	}
+
+	return err
+}
+
+// WriteLegacy writes the level in the format of the original game,
+// anything that does not fit in it is dropped. Boards bigger than
+// LevelSize can't be written.
+func (l *Level) WriteLegacy(wr io.Writer) error {
+	if l.Width > LevelSize || l.Height > LevelSize {
+		return ErrLegacySize
+	}
+
+	w := bufio.NewWriter(wr)
+	for y := 0; y < LevelSize; y++ {
+		for x := 0; x < LevelSize; x++ {
+			w.WriteByte(byte(l.Field.At(x, y)))
+		}
+	}
+
+	for y := 0; y < LevelSize; y++ {
+		for x := 0; x < LevelSize; x++ {
+			w.WriteByte(byte(l.Solution.At(x, y)))
+		}
+	}
+
+	w.WriteByte(byte(l.Duration >> 8))
+	w.WriteByte(byte(l.Duration))
+
+	for i := range l.Desc {
+		for x := 0; x < 15; x++ {
+			w.WriteByte(l.Desc[i][x])
+		}
+	}
+
+	w.WriteByte(byte(l.Cursor))
+	w.WriteByte(byte(l.BG))
+
+	return w.Flush()
+}
+
+func section(w *bufio.Writer, tag string, data []byte) {
+	w.WriteString(tag)
+	binary.Write(w, binary.BigEndian, uint32(len(data)))
+	w.Write(data)
+}
+
+func encodeGrid(g *Grid, width, height int) []byte {
+	buf := []byte{byte(width), byte(height)}
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			buf = append(buf, byte(g.At(x, y)))
+		}
+	}
+	return buf
+}
+
+// Init sets up the puzzle to play the given level.
+func (p *Puzzle) Init(l *Level) {
+	p.Field = l.Field
+	p.Solution = l.Solution
+	p.Match = l.Match
+	p.Cursor.Type = l.Cursor
+	p.Reset()
+}
+
+func readByte(r io.ByteReader) int {
+	b, _ := r.ReadByte()
+	return int(b)
+}
+
+func readShort(r io.ByteReader) int {
+	hi := readByte(r)
+	lo := readByte(r)
+	return hi<<8 | lo
+}
@@ -0,0 +1,74 @@
+//go:build ignore
+
+// Mkatoms writes the built in description of the atom pictures from the
+// atoms file in the assets, run it with go generate after changing it.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/qeedquan/go-atomiks/puzzle"
+)
+
+var (
+	input  = flag.String("i", "../assets/atoms.txt", "atoms file")
+	output = flag.String("o", "atoms.go", "output file")
+)
+
+var directions = [8]string{
+	"North", "NorthEast", "East", "SouthEast",
+	"South", "SouthWest", "West", "NorthWest",
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("mkatoms: ")
+	flag.Parse()
+
+	fd, err := os.Open(*input)
+	if err != nil {
+		log.Fatal(err)
+	}
+	kinds, err := puzzle.ReadAtomKinds(fd)
+	fd.Close()
+	if err != nil {
+		log.Fatalf("%s: %v", *input, err)
+	}
+
+	w := new(bytes.Buffer)
+	fmt.Fprintln(w, "// Code generated by mkatoms.go from assets/atoms.txt; DO NOT EDIT.")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "package puzzle")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "// AtomKinds describes the atom pictures as the atoms file in the assets")
+	fmt.Fprintln(w, "// does, loading the file replaces it.")
+	fmt.Fprintln(w, "var AtomKinds = [NumAtoms]AtomKind{")
+	for i, k := range kinds {
+		if k.Element == "" {
+			continue
+		}
+		var bonds []string
+		for d, n := range k.Bonds {
+			if n > 0 {
+				bonds = append(bonds, fmt.Sprintf("%s: %d", directions[d], n))
+			}
+		}
+		fmt.Fprintf(w, "%d: {%q, Bonds{%s}},\n", i, k.Element, strings.Join(bonds, ", "))
+	}
+	fmt.Fprintln(w, "}")
+
+	src, err := format.Source(w.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile(*output, src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,412 @@
+package puzzle
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Compass directions bonds point in, clockwise from north.
+const (
+	North = iota
+	NorthEast
+	East
+	SouthEast
+	South
+	SouthWest
+	West
+	NorthWest
+)
+
+// Compass returns the unit step for a compass direction.
+func Compass(dir int) image.Point {
+	return compass[dir&7]
+}
+
+var compass = [8]image.Point{
+	{0, -1}, {1, -1}, {1, 0}, {1, 1}, {0, 1}, {-1, 1}, {-1, 0}, {-1, -1},
+}
+
+// Opposite returns the compass direction pointing back the other way.
+func Opposite(dir int) int {
+	return (dir + 4) & 7
+}
+
+var compassNames = [8]string{"n", "ne", "e", "se", "s", "sw", "w", "nw"}
+
+// Bonds counts the bonds an atom picture has in each compass direction,
+// two and three are double and triple bonds.
+type Bonds [8]int
+
+// AtomKind is the element an atom picture shows and the bonds drawn on
+// it. The pictures past the chemical ones are decorations and have no
+// element.
+type AtomKind struct {
+	Element string
+	Bonds   Bonds
+}
+
+//go:generate go run mkatoms.go
+
+// ElementNames are the names of the elements the atoms show.
+var ElementNames = map[string]string{
+	"H": "hydrogen",
+	"O": "oxygen",
+	"N": "nitrogen",
+	"C": "carbon",
+	"F": "fluorine",
+}
+
+// Valence is how many bonds each element makes.
+var Valence = map[string]int{
+	"H": 1,
+	"O": 2,
+	"N": 3,
+	"C": 4,
+	"F": 1,
+}
+
+// Chemical reports whether an atom picture shows an element.
+func Chemical(index int) bool {
+	return 0 <= index && index < NumAtoms && AtomKinds[index].Element != ""
+}
+
+// Total returns the number of bonds counting double and triple bonds as
+// two and three.
+func (b *Bonds) Total() int {
+	n := 0
+	for _, m := range b {
+		n += m
+	}
+	return n
+}
+
+// Sensible reports whether an atom picture shows an element with as many
+// bonds as it makes, one of the pictures in the original game doesn't.
+func Sensible(index int) bool {
+	if !Chemical(index) {
+		return false
+	}
+	k := &AtomKinds[index]
+	return k.Bonds.Total() == Valence[k.Element]
+}
+
+// The atoms file describes the atom pictures one per line, with the index
+// of the picture, its element or a dash for pictures that aren't atoms,
+// and the bonds drawn on it as compass directions followed by 2 or 3 for
+// double and triple bonds:
+//
+//	# index element bonds
+//	8 O w e
+//	15 C ne w2 se
+//	32 -
+//
+// Lines starting with # are comments, pictures that aren't listed have
+// no element.
+const AtomsFile = "atoms.txt"
+
+// LoadAtomKinds replaces the description of the atom pictures with the
+// one in an atoms file.
+func LoadAtomKinds(name string) error {
+	fd, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	k, err := ReadAtomKinds(fd)
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	AtomKinds = k
+	return nil
+}
+
+// ReadAtomKinds reads an atoms file.
+func ReadAtomKinds(r io.Reader) ([NumAtoms]AtomKind, error) {
+	var k [NumAtoms]AtomKind
+	s := bufio.NewScanner(r)
+	for line := 1; s.Scan(); line++ {
+		f := strings.Fields(s.Text())
+		if len(f) == 0 || strings.HasPrefix(f[0], "#") {
+			continue
+		}
+		if len(f) < 2 {
+			return k, fmt.Errorf("line %d: missing element", line)
+		}
+		i, err := strconv.Atoi(f[0])
+		if err != nil || i < 0 || i >= NumAtoms {
+			return k, fmt.Errorf("line %d: bad atom index %q", line, f[0])
+		}
+		if f[1] == "-" {
+			k[i] = AtomKind{}
+			continue
+		}
+		k[i].Element = f[1]
+		k[i].Bonds, err = parseBonds(f[2:])
+		if err != nil {
+			return k, fmt.Errorf("line %d: %v", line, err)
+		}
+	}
+	return k, s.Err()
+}
+
+func parseBonds(f []string) (Bonds, error) {
+	var b Bonds
+	for _, s := range f {
+		n := 1
+		if c := s[len(s)-1]; '2' <= c && c <= '3' {
+			n = int(c - '0')
+			s = s[:len(s)-1]
+		}
+		d := -1
+		for i, name := range compassNames {
+			if strings.EqualFold(s, name) {
+				d = i
+			}
+		}
+		if d < 0 {
+			return b, fmt.Errorf("bad bond %q", s)
+		}
+		b[d] = n
+	}
+	return b, nil
+}
+
+// String returns the bonds the way they are written in the atoms file.
+func (b Bonds) String() string {
+	var s []string
+	for d, n := range b {
+		switch {
+		case n == 1:
+			s = append(s, compassNames[d])
+		case n > 1:
+			s = append(s, compassNames[d]+strconv.Itoa(n))
+		}
+	}
+	return strings.Join(s, " ")
+}
+
+// bond returns the bond the atom at a square of a molecule has in a
+// direction, or zero if there is no atom.
+func bond(m *Grid, p image.Point, dir int) int {
+	if p.X < 0 || p.Y < 0 || p.X >= m.Width || p.Y >= m.Height || m.Type(p.X, p.Y) != ATOM {
+		return 0
+	}
+	i := m.Index(p.X, p.Y)
+	if !Chemical(i) {
+		return 0
+	}
+	return AtomKinds[i].Bonds[dir]
+}
+
+// LooseBonds returns the atoms of a molecule with a bond that doesn't
+// meet the same bond of an atom on the other end.
+func (g *Grid) LooseBonds() []image.Point {
+	var loose []image.Point
+	for y := 0; y < g.Height; y++ {
+		for x := 0; x < g.Width; x++ {
+			p := image.Pt(x, y)
+			for d := range compass {
+				if n := bond(g, p, d); n > 0 && bond(g, p.Add(compass[d]), Opposite(d)) != n {
+					loose = append(loose, p)
+					break
+				}
+			}
+		}
+	}
+	return loose
+}
+
+// Composition counts the atoms of each element in a grid, it reports
+// false if any of the atoms isn't an element.
+func (g *Grid) Composition() (map[string]int, bool) {
+	count := make(map[string]int)
+	for y := 0; y < g.Height; y++ {
+		for x := 0; x < g.Width; x++ {
+			if g.Type(x, y) != ATOM {
+				continue
+			}
+			i := g.Index(x, y)
+			if !Chemical(i) {
+				return nil, false
+			}
+			count[AtomKinds[i].Element]++
+		}
+	}
+	return count, true
+}
+
+// Formula returns the chemical formula of the atoms in a grid, written
+// the Hill way with carbon and hydrogen first and the other elements in
+// alphabetical order, or all in alphabetical order without carbon. It is
+// empty if any of the atoms isn't an element.
+func (g *Grid) Formula() string {
+	count, ok := g.Composition()
+	if !ok {
+		return ""
+	}
+
+	var elements []string
+	for e := range count {
+		if count["C"] == 0 || (e != "C" && e != "H") {
+			elements = append(elements, e)
+		}
+	}
+	sort.Strings(elements)
+	if count["C"] > 0 {
+		elements = append([]string{"C", "H"}, elements...)
+	}
+
+	var s strings.Builder
+	for _, e := range elements {
+		switch n := count[e]; {
+		case n == 1:
+			s.WriteString(e)
+		case n > 1:
+			fmt.Fprintf(&s, "%s%d", e, n)
+		}
+	}
+	return s.String()
+}
+
+// HasFormula reports whether a formula has the same atoms as the grid,
+// however it is written.
+func (g *Grid) HasFormula(formula string) bool {
+	count, ok := g.Composition()
+	if !ok {
+		return false
+	}
+	atoms, err := ParseFormula(formula)
+	if err != nil || len(atoms) != len(count) {
+		return false
+	}
+	for e, n := range count {
+		if atoms[e] != n {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseFormula counts the atoms of each element in a formula made of
+// element symbols each followed by an optional count, such as CH3COOH.
+func ParseFormula(formula string) (map[string]int, error) {
+	count := make(map[string]int)
+	for i := 0; i < len(formula); {
+		c := formula[i]
+		if c < 'A' || c > 'Z' {
+			return nil, fmt.Errorf("puzzle: formula %q: unexpected %q", formula, c)
+		}
+		j := i + 1
+		for j < len(formula) && 'a' <= formula[j] && formula[j] <= 'z' {
+			j++
+		}
+		e := formula[i:j]
+
+		i = j
+		for j < len(formula) && '0' <= formula[j] && formula[j] <= '9' {
+			j++
+		}
+		n := 1
+		if j > i {
+			n, _ = strconv.Atoi(formula[i:j])
+		}
+		count[e] += n
+		i = j
+	}
+	return count, nil
+}
+
+// Turns returns the grid and, if match has MatchTurned, the different
+// grids it makes turned by quarter turns and mirrored. Atoms are swapped
+// for the pictures with their bonds turned the same way, so a turn that
+// needs a picture there isn't wouldn't build the same molecule and is
+// left out. With MatchShape the atoms don't matter and are kept.
+func (g *Grid) Turns(match int) []Grid {
+	turns := []Grid{*g}
+	if match&MatchTurned == 0 {
+		return turns
+	}
+next:
+	for t := 1; t < 8; t++ {
+		v, ok := g.turn(t, match&MatchShape != 0)
+		if !ok {
+			continue
+		}
+		for i := range turns {
+			if turns[i] == v {
+				continue next
+			}
+		}
+		turns = append(turns, v)
+	}
+	return turns
+}
+
+// turn mirrors the grid left to right if t is odd and then turns it
+// clockwise by t/2 quarter turns.
+func (g *Grid) turn(t int, shape bool) (Grid, bool) {
+	var v Grid
+	v.Width, v.Height = g.Width, g.Height
+	if t/2%2 == 1 {
+		v.Width, v.Height = g.Height, g.Width
+	}
+	for y := 0; y < g.Height; y++ {
+		for x := 0; x < g.Width; x++ {
+			sq := g.At(x, y)
+			if sq&TYPE == ATOM && !shape {
+				i, ok := turnAtom(sq&INDEX, t)
+				if !ok {
+					return v, false
+				}
+				sq = ATOM | i
+			}
+			p := turnPoint(image.Pt(x, y), g.Width, g.Height, t)
+			v.Set(p.X, p.Y, sq)
+		}
+	}
+	return v, true
+}
+
+func turnPoint(p image.Point, w, h, t int) image.Point {
+	if t&1 != 0 {
+		p.X = w - 1 - p.X
+	}
+	for i := 0; i < t/2; i++ {
+		p = image.Pt(h-1-p.Y, p.X)
+		w, h = h, w
+	}
+	return p
+}
+
+func turnDir(dir, t int) int {
+	if t&1 != 0 {
+		dir = 8 - dir
+	}
+	return (dir + 2*(t/2)) & 7
+}
+
+// turnAtom returns the picture of the same element as an atom with its
+// bonds turned, pictures that aren't atoms stay the same.
+func turnAtom(index, t int) (int, bool) {
+	if !Chemical(index) {
+		return index, true
+	}
+	k := &AtomKinds[index]
+	var b Bonds
+	for d, n := range k.Bonds {
+		b[turnDir(d, t)] = n
+	}
+	for i := range AtomKinds {
+		if AtomKinds[i].Element == k.Element && AtomKinds[i].Bonds == b {
+			return i, true
+		}
+	}
+	return 0, false
+}
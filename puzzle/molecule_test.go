@@ -0,0 +1,57 @@
+package puzzle
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHasFormula(t *testing.T) {
+	// water laid out as H-O-H
+	var g Grid
+	g.Set(0, 0, ATOM|6)
+	g.Set(1, 0, ATOM|8)
+	g.Set(2, 0, ATOM|2)
+	g.Fit()
+
+	tests := []struct {
+		formula string
+		ok      bool
+	}{
+		{"H2O", true},
+		{"HOH", true},
+		{"OH2", true},
+		{"H2O2", false},
+		{"HO", false},
+		{"H2OC", false},
+		{"", false},
+		{"h2o", false},
+		{"H2-O", false},
+	}
+	for _, tt := range tests {
+		if ok := g.HasFormula(tt.formula); ok != tt.ok {
+			t.Errorf("HasFormula(%q) = %v, want %v", tt.formula, ok, tt.ok)
+		}
+	}
+	if f := g.Formula(); f != "H2O" {
+		t.Errorf("Formula() = %q, want H2O", f)
+	}
+}
+
+func TestAtomKindsFile(t *testing.T) {
+	fd, err := os.Open(filepath.Join("..", "assets", AtomsFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+
+	k, err := ReadAtomKinds(fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range k {
+		if k[i] != AtomKinds[i] {
+			t.Errorf("atom %d is %v in the atoms file but %v built in, run go generate", i, k[i], AtomKinds[i])
+		}
+	}
+}
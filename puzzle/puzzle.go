@@ -0,0 +1,407 @@
+// Package puzzle implements the rules of Atomiks without any dependency on
+// SDL, so that the game, the editor and command line tools all drive the
+// same model.
+package puzzle
+
+import "image"
+
+const (
+	FREE  = 128
+	ATOM  = 64
+	WALL  = 192
+	TYPE  = 192
+	INDEX = 63
+)
+
+// Number of atom and wall pictures the game has, indices past these can't
+// be drawn.
+const (
+	NumAtoms = 49
+	NumWalls = 19
+)
+
+const (
+	UP = iota + 1
+	RIGHT
+	DOWN
+	LEFT
+)
+
+const (
+	StartScore  = 500
+	MovePenalty = 5
+)
+
+// Ways a molecule built on the field can match the solution other than
+// atom for atom like in the original game, levels can allow any atom
+// on any square of the solution or the molecule to be turned around or
+// mirrored.
+const (
+	MatchShape = 1 << iota
+	MatchTurned
+)
+
+// MaxSize is the largest width and height a board can have.
+const MaxSize = 64
+
+type Grid struct {
+	Squares [MaxSize][MaxSize]int
+	Width   int
+	Height  int
+}
+
+type Cursor struct {
+	image.Point
+	Type  int
+	State int
+}
+
+type Puzzle struct {
+	Field     Grid
+	Solution  Grid
+	Match     int
+	Cursor    Cursor
+	Score     int
+	Penalty   int
+	Refund    bool
+	Moves     int
+	Hints     int
+	done      []step
+	undone    []step
+	solutions []Grid
+}
+
+// step is a move in the undo history along with the points it cost.
+type step struct {
+	Move
+	cost int
+}
+
+// Delta returns the unit step for a direction.
+func Delta(dir int) image.Point {
+	switch dir {
+	case UP:
+		return image.Pt(0, -1)
+	case RIGHT:
+		return image.Pt(1, 0)
+	case DOWN:
+		return image.Pt(0, 1)
+	case LEFT:
+		return image.Pt(-1, 0)
+	}
+	return image.ZP
+}
+
+func (g *Grid) Set(x, y, v int) {
+	g.Squares[y][x] = v
+}
+
+func (g *Grid) At(x, y int) int {
+	return g.Squares[y][x]
+}
+
+func (g *Grid) Type(x, y int) int {
+	return g.Squares[y][x] & TYPE
+}
+
+func (g *Grid) Index(x, y int) int {
+	return g.Squares[y][x] & INDEX
+}
+
+func (g *Grid) Inside(x, y int) bool {
+	return 0 <= x && x < len(g.Squares[0]) && 0 <= y && y < len(g.Squares)
+}
+
+// Fit sets the width and height of the grid to the bounding box of
+// its atoms and walls.
+func (g *Grid) Fit() {
+	g.Width, g.Height = 0, 0
+	for y := range g.Squares {
+		for x := range g.Squares[y] {
+			if t := g.Type(x, y); t == ATOM || t == WALL {
+				if x+1 > g.Width {
+					g.Width = x + 1
+				}
+				if y+1 > g.Height {
+					g.Height = y + 1
+				}
+			}
+		}
+	}
+}
+
+// Distance returns how many squares the atom at x, y would slide
+// in the given direction before hitting something.
+func (g *Grid) Distance(x, y, dir int) int {
+	if g.Type(x, y) != ATOM {
+		return 0
+	}
+
+	i := 0
+	switch dir {
+	case UP:
+		y--
+		for i = y; i >= 0; i-- {
+			if g.Type(x, i) != FREE {
+				break
+			}
+		}
+		return y - i
+	case RIGHT:
+		x++
+		for i = x; i < MaxSize; i++ {
+			if g.Type(i, y) != FREE {
+				break
+			}
+		}
+		return i - x
+	case DOWN:
+		y++
+		for i = y; i < MaxSize; i++ {
+			if g.Type(x, i) != FREE {
+				break
+			}
+		}
+		return i - y
+	case LEFT:
+		x--
+		for i = x; i >= 0; i-- {
+			if g.Type(i, y) != FREE {
+				break
+			}
+		}
+		return x - i
+	}
+
+	return 0
+}
+
+// Slide moves the atom at x, y as far as it goes in the given direction
+// and returns where it stopped along with the distance travelled.
+func (g *Grid) Slide(x, y, dir int) (image.Point, int) {
+	p := image.Pt(x, y)
+	d := g.Distance(x, y, dir)
+	if d == 0 {
+		return p, 0
+	}
+
+	v := g.At(x, y)
+	g.Set(x, y, FREE)
+	p = p.Add(Delta(dir).Mul(d))
+	g.Set(p.X, p.Y, v)
+	return p, d
+}
+
+// Matches reports whether the field square a fills the solution square b,
+// the atoms have to be the same unless match has MatchShape.
+func Matches(a, b, match int) bool {
+	if a&TYPE != ATOM || b&TYPE != ATOM {
+		return false
+	}
+	return a == b || match&MatchShape != 0
+}
+
+// Contains reports whether the solution pattern s appears in g with its
+// top left corner at x, y.
+func (g *Grid) Contains(s *Grid, x, y, match int) bool {
+	for yy := 0; yy < s.Height; yy++ {
+		for xx := 0; xx < s.Width; xx++ {
+			if s.Type(xx, yy) == ATOM && !Matches(g.At(x+xx, y+yy), s.At(xx, yy), match) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Reset prepares a freshly loaded field and solution for play.
+func (p *Puzzle) Reset() {
+	p.Field.Fit()
+	p.Solution.Fit()
+	p.Cursor.Point = image.ZP
+	p.Cursor.State = 0
+	p.Score = StartScore
+	p.Penalty = MovePenalty
+	p.Moves = 0
+	p.Hints = 0
+	p.done = p.done[:0]
+	p.undone = p.undone[:0]
+	p.solutions = p.Solution.Turns(p.Match)
+
+	for y := 0; y < p.Field.Height; y++ {
+		for x := 0; x < p.Field.Width; x++ {
+			t := p.Field.Type(x, y)
+			if (t == ATOM || t == FREE) && p.Cursor.Point == image.ZP {
+				p.Cursor.Point = image.Pt(x, y)
+			}
+		}
+	}
+}
+
+func (p *Puzzle) MovedDistance(dir int) int {
+	return p.Field.Distance(p.Cursor.X, p.Cursor.Y, dir)
+}
+
+// MoveCursor steps the cursor by dx, dy if the destination is part
+// of the board.
+func (p *Puzzle) MoveCursor(dx, dy int) bool {
+	x := p.Cursor.X + dx
+	y := p.Cursor.Y + dy
+	if !p.Field.Inside(x, y) || p.Field.At(x, y) == 0 {
+		return false
+	}
+	p.Cursor.X, p.Cursor.Y = x, y
+	return true
+}
+
+// Select toggles the selection of the atom under the cursor and
+// reports whether an atom is now selected.
+func (p *Puzzle) Select() bool {
+	c := &p.Cursor
+	if p.Field.Type(c.X, c.Y) != ATOM {
+		return false
+	}
+	if c.State == 0 {
+		c.State = c.Type
+		return true
+	}
+	c.State = 0
+	return false
+}
+
+// Slide moves the selected atom in the given direction, dragging the
+// cursor along and charging the move penalty. It returns the distance
+// travelled, which is zero if the atom could not move.
+func (p *Puzzle) Slide(dir int) int {
+	c := &p.Cursor
+	if c.State == 0 {
+		return 0
+	}
+
+	from := c.Point
+	pt, d := p.Field.Slide(c.X, c.Y, dir)
+	if d == 0 {
+		return 0
+	}
+	c.Point = pt
+
+	p.Moves++
+	p.done = append(p.done, step{Move{from, pt, dir}, p.charge()})
+	p.undone = p.undone[:0]
+	return d
+}
+
+// Undo takes back the last slide, putting the atom and the cursor back
+// where they were. The move penalty is given back if Refund is set.
+func (p *Puzzle) Undo() (Move, bool) {
+	n := len(p.done)
+	if n == 0 {
+		return Move{}, false
+	}
+
+	s := p.done[n-1]
+	p.done = p.done[:n-1]
+	p.Field.Set(s.From.X, s.From.Y, p.Field.At(s.To.X, s.To.Y))
+	p.Field.Set(s.To.X, s.To.Y, FREE)
+	p.Cursor.Point = s.From
+	p.Moves--
+	if p.Refund {
+		p.Score += s.cost
+		s.cost = 0
+	}
+	p.undone = append(p.undone, s)
+	return s.Move, true
+}
+
+// Redo replays the last undone slide, charging for it again if it
+// was refunded.
+func (p *Puzzle) Redo() (Move, bool) {
+	n := len(p.undone)
+	if n == 0 {
+		return Move{}, false
+	}
+
+	s := p.undone[n-1]
+	p.undone = p.undone[:n-1]
+	p.Field.Set(s.To.X, s.To.Y, p.Field.At(s.From.X, s.From.Y))
+	p.Field.Set(s.From.X, s.From.Y, FREE)
+	p.Cursor.Point = s.To
+	p.Moves++
+	if p.Refund {
+		s.cost = p.charge()
+	}
+	p.done = append(p.done, s)
+	return s.Move, true
+}
+
+// charge takes the move penalty off the score and returns how much
+// was actually taken.
+func (p *Puzzle) charge() int {
+	n := p.Score
+	p.Score -= p.Penalty
+	if p.Score < 0 {
+		p.Score = 0
+	}
+	return n - p.Score
+}
+
+// Hint returns the first move of a solution from the current field.
+func (p *Puzzle) Hint(s *Solver) (Move, error) {
+	moves, _, err := s.Solve(p)
+	if err != nil {
+		return Move{}, err
+	}
+	if len(moves) == 0 {
+		return Move{}, ErrUnsolvable
+	}
+	return moves[0], nil
+}
+
+// TakeHint charges for a hint the same as for a move.
+func (p *Puzzle) TakeHint() {
+	p.Hints++
+	p.charge()
+}
+
+// Stars rates a win from one to three stars by comparing the number of
+// moves against par, or returns zero if par is not known.
+func Stars(moves, par int) int {
+	switch {
+	case par <= 0:
+		return 0
+	case moves <= par:
+		return 3
+	case 2*moves <= 3*par:
+		return 2
+	}
+	return 1
+}
+
+// Solutions returns the solution and the other ways it can be built if
+// the level allows the molecule to be turned.
+func (p *Puzzle) Solutions() []Grid {
+	if p.solutions == nil {
+		p.solutions = p.Solution.Turns(p.Match)
+	}
+	return p.solutions
+}
+
+func (p *Puzzle) Won() bool {
+	fx, fy := p.Field.Width, p.Field.Height
+	if fx == 0 || fy == 0 {
+		return false
+	}
+
+	sols := p.Solutions()
+	for i := range sols {
+		s := &sols[i]
+		for y := 0; y <= fy-s.Height; y++ {
+			for x := 0; x <= fx-s.Width; x++ {
+				if p.Field.Contains(s, x, y, p.Match) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,205 @@
+package puzzle
+
+import (
+	"image"
+	"path/filepath"
+	"testing"
+)
+
+// won reports whether a field with the squares of f wins a puzzle with
+// solution s.
+func won(f, s *Grid, match int) bool {
+	p := Puzzle{Field: *f, Solution: *s, Match: match}
+	p.Reset()
+	return p.Won()
+}
+
+func TestWonShippedLevels(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("..", "assets", "lev", "lev00[0-9][0-9].dat"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no levels found")
+	}
+
+	turned := 0
+	for _, name := range files {
+		name := name
+		t.Run(filepath.Base(name), func(t *testing.T) {
+			l, err := LoadLevel(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var p Puzzle
+			p.Init(l)
+			sol := p.Solution
+
+			if p.Won() {
+				t.Errorf("won before any move")
+			}
+			p.Field = sol
+			if !p.Won() {
+				t.Errorf("solution copied on the field doesn't win")
+			}
+
+			wrong := sol
+			for y := 0; y < wrong.Height; y++ {
+				for x := 0; x < wrong.Width; x++ {
+					if wrong.Type(x, y) == ATOM {
+						wrong.Set(x, y, ATOM|(wrong.Index(x, y)+1)%NumAtoms)
+						y = wrong.Height
+						break
+					}
+				}
+			}
+			if won(&wrong, &sol, 0) {
+				t.Errorf("a wrong atom wins when atoms are compared")
+			}
+			if !won(&wrong, &sol, MatchShape) {
+				t.Errorf("a wrong atom doesn't win when any atom will do")
+			}
+
+			for i := 1; i < 8; i++ {
+				v, ok := sol.turn(i, false)
+				if !ok || v == sol {
+					continue
+				}
+				if won(&v, &sol, 0) {
+					t.Errorf("turn %d wins without turned matching", i)
+				}
+				if !won(&v, &sol, MatchTurned) {
+					t.Errorf("turn %d doesn't win with turned matching", i)
+				}
+				turned++
+			}
+		})
+	}
+	if turned == 0 {
+		t.Errorf("no level could be turned")
+	}
+}
+
+func TestTurnsShape(t *testing.T) {
+	var sol Grid
+	sol.Set(0, 0, ATOM|6)
+	sol.Set(1, 0, ATOM|8)
+	sol.Set(2, 0, ATOM|2)
+	sol.Fit()
+
+	var f Grid
+	f.Set(1, 0, ATOM|2)
+	f.Set(1, 1, ATOM|13)
+	f.Set(1, 2, ATOM|2)
+	f.Fit()
+	if won(&f, &sol, MatchTurned) {
+		t.Errorf("standing water with other atoms wins with turned matching")
+	}
+	if won(&f, &sol, MatchShape) {
+		t.Errorf("standing water wins without turned matching")
+	}
+	if !won(&f, &sol, MatchShape|MatchTurned) {
+		t.Errorf("standing water doesn't win with shape and turned matching")
+	}
+}
+
+// corridor returns a puzzle with atoms on the left of two rows of free
+// squares, sliding the top one right up to the atom at its end wins.
+func corridor(refund bool) *Puzzle {
+	p := &Puzzle{Refund: refund}
+	for x := 0; x < 5; x++ {
+		p.Field.Set(x, 0, FREE)
+		p.Field.Set(x, 1, FREE)
+	}
+	p.Field.Set(0, 0, ATOM|2)
+	p.Field.Set(5, 0, ATOM|6)
+	p.Field.Set(0, 1, ATOM|13)
+	p.Solution.Set(0, 0, ATOM|2)
+	p.Solution.Set(1, 0, ATOM|6)
+	p.Cursor.Type = 1
+	p.Reset()
+	return p
+}
+
+// slide selects the atom at x, y and slides it in the direction dir.
+func slide(t *testing.T, p *Puzzle, x, y, dir int) {
+	t.Helper()
+	p.Cursor.Point = image.Pt(x, y)
+	p.Cursor.State = 0
+	if !p.Select() {
+		t.Fatalf("no atom to select at %d,%d", x, y)
+	}
+	if p.Slide(dir) == 0 {
+		t.Fatalf("atom at %d,%d didn't slide", x, y)
+	}
+}
+
+func TestUndoAfterWin(t *testing.T) {
+	p := corridor(false)
+	start := p.Field
+	slide(t, p, 0, 0, RIGHT)
+	if !p.Won() {
+		t.Fatal("sliding against the other atom doesn't win")
+	}
+
+	m, ok := p.Undo()
+	if !ok || m.From != image.Pt(0, 0) || m.To != image.Pt(4, 0) {
+		t.Fatalf("undo after the win gave %v, %v", m, ok)
+	}
+	if p.Won() || p.Field != start || p.Moves != 0 || p.Cursor.Point != image.Pt(0, 0) {
+		t.Errorf("undo after the win didn't put the field back")
+	}
+
+	if _, ok := p.Redo(); !ok || !p.Won() || p.Moves != 1 {
+		t.Errorf("redo of the winning move doesn't win again")
+	}
+}
+
+func TestRedoClearedByMove(t *testing.T) {
+	p := corridor(false)
+	slide(t, p, 0, 0, RIGHT)
+	p.Undo()
+	slide(t, p, 0, 1, RIGHT)
+
+	if m, ok := p.Redo(); ok {
+		t.Errorf("redo after a new move gave %v", m)
+	}
+	m, ok := p.Undo()
+	if !ok || m.From != image.Pt(0, 1) {
+		t.Errorf("undo after a new move gave %v, %v", m, ok)
+	}
+	if _, ok := p.Undo(); ok {
+		t.Errorf("undo went past the start")
+	}
+}
+
+func TestRefundMoves(t *testing.T) {
+	tests := []struct {
+		refund       bool
+		undone, redo int
+		score        int
+	}{
+		{false, 2, 0, StartScore - 2*MovePenalty},
+		{true, 2, 0, StartScore},
+		{true, 1, 0, StartScore - MovePenalty},
+		{true, 2, 1, StartScore - MovePenalty},
+		{false, 2, 2, StartScore - 2*MovePenalty},
+	}
+	for _, tt := range tests {
+		p := corridor(tt.refund)
+		slide(t, p, 0, 0, RIGHT)
+		slide(t, p, 0, 1, RIGHT)
+		for i := 0; i < tt.undone; i++ {
+			p.Undo()
+		}
+		for i := 0; i < tt.redo; i++ {
+			p.Redo()
+		}
+
+		moves := 2 - tt.undone + tt.redo
+		if p.Moves != moves || p.Score != tt.score {
+			t.Errorf("refund %v, %d undone, %d redone: %d moves scoring %d, want %d scoring %d",
+				tt.refund, tt.undone, tt.redo, p.Moves, p.Score, moves, tt.score)
+		}
+	}
+}
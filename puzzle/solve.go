@@ -0,0 +1,476 @@
+package puzzle
+
+import (
+	"errors"
+	"image"
+	"sort"
+)
+
+var (
+	ErrUnsolvable = errors.New("puzzle: no solution")
+	ErrLimit      = errors.New("puzzle: search limit reached")
+)
+
+// Move is a single slide of the atom at From in direction Dir, coming
+// to rest at To.
+type Move struct {
+	From image.Point
+	To   image.Point
+	Dir  int
+}
+
+// Solver searches for sequences of moves that win a puzzle.
+type Solver struct {
+	// Limit is the maximum number of states expanded before giving up,
+	// zero means no limit.
+	Limit int
+
+	// Weight scales the estimate of the moves left. A weight of one or
+	// less finds shortest solutions, larger weights find longer ones faster.
+	Weight int
+
+	// Build is how many states may be expanded building the molecule one
+	// atom at a time once the search of the whole puzzle reaches Limit,
+	// zero means not to try. Built solutions are longer, but big molecules
+	// take a small part of the states.
+	Build int
+}
+
+type search struct {
+	board  Puzzle
+	atoms  []int
+	group  [][2]int
+	places []placement
+	dist   [][]uint8
+	goals  []int
+	near   [][]uint8
+	first  [][]uint8
+	second [][]uint8
+	moved  []uint8
+	width  int
+	weight int
+	nodes  []node
+	open   [][]int
+	best   map[string]int
+}
+
+// placement is somewhere the solution, or a turned way of building it,
+// fits on the field. It has the squares each group of atoms has to fill
+// and how far every square is from them.
+type placement struct {
+	at       image.Point
+	targets  [][]image.Point
+	balanced []bool
+	reach    [][]uint8
+}
+
+type node struct {
+	key    string
+	parent int
+	cost   int
+	move   Move
+}
+
+// Solve returns a shortest sequence of slides that wins the puzzle from
+// its current field, expanding at most limit states.
+func Solve(p *Puzzle, limit int) ([]Move, error) {
+	s := Solver{Limit: limit}
+	moves, _, err := s.Solve(p)
+	return moves, err
+}
+
+// Solve runs an A* search over board states starting from the current
+// field of the puzzle. Atoms that are interchangeable in the solution are
+// treated as identical, so states only differing by swapping them are
+// visited once. If the search gives up and Build is set, the molecule is
+// built one atom at a time instead. The solution found is only known to
+// be a shortest one when shortest is set, which needs a weight of one or
+// less and the search to finish within Limit.
+func (v *Solver) Solve(p *Puzzle) (moves []Move, shortest bool, err error) {
+	moves, _, err = v.run(p, v.Limit)
+	if err == ErrLimit && v.Build > 0 {
+		moves, err = v.build(p)
+		return moves, false, err
+	}
+	return moves, err == nil && v.Weight <= 1, err
+}
+
+// run searches the whole puzzle expanding at most limit states, it also
+// returns how many states were expanded.
+func (v *Solver) run(p *Puzzle, limit int) ([]Move, int, error) {
+	s := &search{
+		weight: v.Weight,
+		best:   make(map[string]int),
+	}
+	if s.weight < 1 {
+		s.weight = 1
+	}
+	start := s.init(p)
+	for i := range s.group {
+		s.nearest(start, i)
+	}
+	h := s.estimate([]byte(start))
+	if h < 0 {
+		return nil, 0, ErrUnsolvable
+	}
+	s.push(start, -1, 0, 0, h, Move{})
+
+	expanded := 0
+	for f := 0; f < len(s.open); f++ {
+		for len(s.open[f]) > 0 {
+			i := s.open[f][len(s.open[f])-1]
+			s.open[f] = s.open[f][:len(s.open[f])-1]
+
+			n := s.nodes[i]
+			if s.best[n.key] < n.cost {
+				continue
+			}
+			if limit > 0 && expanded >= limit {
+				return nil, expanded, ErrLimit
+			}
+			expanded++
+
+			s.place(n.key)
+			if s.board.Won() {
+				return s.path(i), expanded, nil
+			}
+			s.expand(i, f)
+			s.clear(n.key)
+		}
+	}
+	return nil, expanded, ErrUnsolvable
+}
+
+// init copies the field with its atoms taken out and returns the
+// starting state. A state lists the position of every atom as x, y
+// byte pairs, ordered by atom value and then by position.
+func (s *search) init(p *Puzzle) string {
+	type atom struct {
+		image.Point
+		value int
+	}
+
+	s.board.Field = p.Field
+	s.board.Solution = p.Solution
+	s.board.Match = p.Match
+	s.board.solutions = p.Solutions()
+
+	var atoms []atom
+	f := &s.board.Field
+	for y := range f.Squares {
+		for x := range f.Squares[y] {
+			if f.Type(x, y) == ATOM {
+				atoms = append(atoms, atom{image.Pt(x, y), f.At(x, y)})
+				f.Set(x, y, FREE)
+			}
+		}
+	}
+	sort.SliceStable(atoms, func(i, j int) bool {
+		return atoms[i].value < atoms[j].value
+	})
+
+	key := make([]byte, 0, 2*len(atoms))
+	for i, a := range atoms {
+		s.atoms = append(s.atoms, a.value)
+		if i == 0 || !Matches(a.value, atoms[i-1].value, p.Match) {
+			s.group = append(s.group, [2]int{i, i + 1})
+		} else {
+			s.group[len(s.group)-1][1] = i + 1
+		}
+		key = append(key, byte(a.X), byte(a.Y))
+	}
+	for _, g := range s.group {
+		canonical(key, g)
+	}
+
+	s.distances()
+	sols := s.board.solutions
+	for i := range sols {
+		s.placements(&sols[i])
+	}
+
+	n := f.Width * f.Height
+	seen := make([]bool, n)
+	for _, pl := range s.places {
+		for _, targets := range pl.targets {
+			for _, t := range targets {
+				if c := (t.Y+pl.at.Y)*s.width + t.X + pl.at.X; !seen[c] {
+					seen[c] = true
+					s.goals = append(s.goals, c)
+				}
+			}
+		}
+	}
+	s.near = make([][]uint8, len(s.group))
+	s.first = make([][]uint8, len(s.group))
+	s.second = make([][]uint8, len(s.group))
+	s.moved = make([]uint8, n)
+	for i := range s.group {
+		s.first[i] = make([]uint8, n)
+		s.second[i] = make([]uint8, n)
+	}
+
+	return string(key)
+}
+
+// placements adds the places a solution fits on the field.
+func (s *search) placements(sol *Grid) {
+	f := &s.board.Field
+	targets := make([][]image.Point, len(s.group))
+	balanced := make([]bool, len(s.group))
+	for y := 0; y < sol.Height; y++ {
+		for x := 0; x < sol.Width; x++ {
+			if sol.Type(x, y) != ATOM {
+				continue
+			}
+			for i, g := range s.group {
+				if Matches(sol.At(x, y), s.atoms[g[0]], s.board.Match) {
+					targets[i] = append(targets[i], image.Pt(x, y))
+					break
+				}
+			}
+		}
+	}
+	for i, g := range s.group {
+		balanced[i] = len(targets[i]) == g[1]-g[0]
+	}
+
+	n := f.Width * f.Height
+	for y := 0; y <= f.Height-sol.Height; y++ {
+		for x := 0; x <= f.Width-sol.Width; x++ {
+			o := image.Pt(x, y)
+			pl := placement{o, targets, balanced, make([][]uint8, len(s.group))}
+			for i := range s.group {
+				reach := make([]uint8, n)
+				for c := range reach {
+					reach[c] = 255
+					for _, t := range targets[i] {
+						if v := s.dist[(t.Y+o.Y)*s.width+t.X+o.X][c]; v < reach[c] {
+							reach[c] = v
+						}
+					}
+				}
+				pl.reach[i] = reach
+			}
+			s.places = append(s.places, pl)
+		}
+	}
+}
+
+// distances fills in a table of how many slides it takes to get from one
+// square to another if atoms could stop anywhere, which never overestimates
+// the real number of moves.
+func (s *search) distances() {
+	f := &s.board.Field
+	s.width = f.Width
+	n := f.Width * f.Height
+	s.dist = make([][]uint8, n)
+	for i := range s.dist {
+		d := make([]uint8, n)
+		for j := range d {
+			d[j] = 255
+		}
+		s.dist[i] = d
+
+		x, y := i%f.Width, i/f.Width
+		if f.Type(x, y) != FREE {
+			continue
+		}
+
+		d[i] = 0
+		queue := []image.Point{image.Pt(x, y)}
+		for len(queue) > 0 {
+			p := queue[0]
+			queue = queue[1:]
+			c := d[p.Y*f.Width+p.X]
+			for dir := UP; dir <= LEFT; dir++ {
+				q := p.Add(Delta(dir))
+				for q.In(image.Rect(0, 0, f.Width, f.Height)) && f.Type(q.X, q.Y) == FREE {
+					if j := q.Y*f.Width + q.X; d[j] == 255 {
+						d[j] = c + 1
+						queue = append(queue, q)
+					}
+					q = q.Add(Delta(dir))
+				}
+			}
+		}
+	}
+}
+
+// nearest records for every square the molecule can need filled the
+// smallest and second smallest distance to an atom of the group, so that the closest atom to a square
+// after moving one atom of the group can be found without looking at
+// the others.
+func (s *search) nearest(key string, i int) {
+	g := s.group[i]
+	first, second := s.first[i], s.second[i]
+	for _, c := range s.goals {
+		d := s.dist[c]
+		m1, m2 := uint8(255), uint8(255)
+		for j := g[0]; j < g[1]; j++ {
+			v := d[int(key[2*j+1])*s.width+int(key[2*j])]
+			if v < m1 {
+				m1, m2 = v, m1
+			} else if v < m2 {
+				m2 = v
+			}
+		}
+		first[c], second[c] = m1, m2
+	}
+	s.near[i] = first
+}
+
+// move updates the closest atom distances of a group for one of its atoms
+// moving between two squares.
+func (s *search) move(i int, from, to image.Point) {
+	first, second := s.first[i], s.second[i]
+	a := from.Y*s.width + from.X
+	b := to.Y*s.width + to.X
+	for _, c := range s.goals {
+		d := s.dist[c]
+		m := first[c]
+		if d[a] == m {
+			m = second[c]
+		}
+		if d[b] < m {
+			m = d[b]
+		}
+		s.moved[c] = m
+	}
+	s.near[i] = s.moved
+}
+
+// estimate returns a lower bound on the number of moves left. For every
+// placement of the solution, each target square needs an atom to reach it
+// and each atom needs to reach some target square, so the larger of the
+// two sums of distances bounds the placement and the best placement bounds
+// the state. It returns -1 if no placement can be reached at all.
+func (s *search) estimate(key []byte) int {
+	best := -1
+places:
+	for k := range s.places {
+		pl := &s.places[k]
+		o := pl.at
+		ht, ha := 0, 0
+		for i, g := range s.group {
+			near := s.near[i]
+			for _, t := range pl.targets[i] {
+				m := near[(t.Y+o.Y)*s.width+t.X+o.X]
+				if m == 255 {
+					continue places
+				}
+				ht += int(m)
+			}
+			if pl.balanced[i] {
+				reach := pl.reach[i]
+				for j := g[0]; j < g[1]; j++ {
+					ha += int(reach[int(key[2*j+1])*s.width+int(key[2*j])])
+				}
+			}
+		}
+		if ha > ht {
+			ht = ha
+		}
+		if best < 0 || ht < best {
+			best = ht
+		}
+	}
+	return best
+}
+
+// push queues a state reached with the given cost. Its priority is kept
+// at least that of its parent, since the estimate is admissible but may
+// drop by more than one move between neighbouring states.
+func (s *search) push(key string, parent, cost, prio, h int, m Move) {
+	f := cost + s.weight*h
+	if f < prio {
+		f = prio
+	}
+	s.best[key] = cost
+	s.nodes = append(s.nodes, node{key, parent, cost, m})
+	for len(s.open) <= f {
+		s.open = append(s.open, nil)
+	}
+	s.open[f] = append(s.open[f], len(s.nodes)-1)
+}
+
+// seen reports whether a state has already been reached as cheaply.
+func (s *search) seen(key string, cost int) bool {
+	c, ok := s.best[key]
+	return ok && c <= cost
+}
+
+func (s *search) expand(parent, prio int) {
+	f := &s.board.Field
+	n := s.nodes[parent]
+	for i := range s.group {
+		s.nearest(n.key, i)
+	}
+
+	for k, g := range s.group {
+		for i := g[0]; i < g[1]; i++ {
+			from := image.Pt(int(n.key[2*i]), int(n.key[2*i+1]))
+			for dir := UP; dir <= LEFT; dir++ {
+				d := f.Distance(from.X, from.Y, dir)
+				if d == 0 {
+					continue
+				}
+
+				to := from.Add(Delta(dir).Mul(d))
+				next := []byte(n.key)
+				next[2*i] = byte(to.X)
+				next[2*i+1] = byte(to.Y)
+				canonical(next, g)
+
+				key := string(next)
+				if s.seen(key, n.cost+1) {
+					continue
+				}
+				s.move(k, from, to)
+				if h := s.estimate(next); h >= 0 {
+					s.push(key, parent, n.cost+1, prio, h, Move{from, to, dir})
+				}
+			}
+		}
+		s.near[k] = s.first[k]
+	}
+}
+
+func (s *search) place(key string) {
+	for i, v := range s.atoms {
+		s.board.Field.Set(int(key[2*i]), int(key[2*i+1]), v)
+	}
+}
+
+func (s *search) clear(key string) {
+	for i := range s.atoms {
+		s.board.Field.Set(int(key[2*i]), int(key[2*i+1]), FREE)
+	}
+}
+
+func (s *search) path(i int) []Move {
+	var moves []Move
+	for ; s.nodes[i].parent >= 0; i = s.nodes[i].parent {
+		moves = append(moves, s.nodes[i].move)
+	}
+	for i, j := 0, len(moves)-1; i < j; i, j = i+1, j-1 {
+		moves[i], moves[j] = moves[j], moves[i]
+	}
+	return moves
+}
+
+// canonical sorts the positions of interchangeable atoms so that
+// equivalent states share the same key.
+func canonical(key []byte, g [2]int) {
+	for i := g[0] + 1; i < g[1]; i++ {
+		for j := i; j > g[0]; j-- {
+			a := int(key[2*j-1])<<8 | int(key[2*j-2])
+			b := int(key[2*j+1])<<8 | int(key[2*j])
+			if a <= b {
+				break
+			}
+			key[2*j-2], key[2*j] = key[2*j], key[2*j-2]
+			key[2*j-1], key[2*j+1] = key[2*j+1], key[2*j-1]
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package puzzle
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestSolveShortest(t *testing.T) {
+	l, err := LoadLevel(filepath.Join("..", "assets", "lev", "lev0001.dat"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		solver   Solver
+		shortest bool
+	}{
+		{Solver{Limit: 300000}, true},
+		{Solver{Limit: 300000, Weight: 3}, false},
+		{Solver{Limit: 10, Build: 1000000}, false},
+	}
+	for _, tt := range tests {
+		var p Puzzle
+		p.Init(l)
+		moves, shortest, err := tt.solver.Solve(&p)
+		if err != nil {
+			t.Errorf("%+v: %v", tt.solver, err)
+			continue
+		}
+		if shortest != tt.shortest {
+			t.Errorf("%+v: shortest %v, want %v", tt.solver, shortest, tt.shortest)
+		}
+		if n := len(moves); n < 13 || shortest && n != 13 {
+			t.Errorf("%+v: %d moves, the shortest solution has 13", tt.solver, n)
+		}
+
+		for _, m := range moves {
+			if to, _ := p.Field.Slide(m.From.X, m.From.Y, m.Dir); to != m.To {
+				t.Fatalf("%+v: move %v ends at %v", tt.solver, m, to)
+			}
+		}
+		if !p.Won() {
+			t.Errorf("%+v: moves don't win", tt.solver)
+		}
+	}
+}
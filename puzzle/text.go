@@ -0,0 +1,370 @@
+package puzzle
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Text levels are meant to be edited by hand and reviewed in diffs.
+// They start with header lines of a keyword and a value, followed by a
+// legend that maps the characters used in the grids to squares, then the
+// field and solution drawn one row per line:
+//
+//	atomiks 1
+//	size 16 16
+//	time 240
+//	desc "WATER"
+//	desc ""
+//	cursor 1
+//	background 0
+//	title "Water"
+//	molecule "Water"
+//	formula "H2O"
+//	blurb "Two hydrogen atoms bonded to one oxygen atom"
+//
+//	legend
+//	a atom 1
+//	0 wall 3
+//
+//	field
+//	 000000
+//	 0....0
+//	 ...
+//
+// A space is an empty square and a dot is a free square, everything else
+// has to be in the legend. Rows may leave out trailing empty squares.
+// Unknown header keywords are skipped. A "match" line with the words
+// shape or turned lets the molecule be built out of any atoms or turned
+// around.
+const TextExt = ".txt"
+
+var ErrTextSymbols = errors.New("puzzle: too many different squares for a text level")
+
+var typeNames = map[int]string{
+	0:    "empty",
+	FREE: "free",
+	ATOM: "atom",
+	WALL: "wall",
+}
+
+const (
+	atomSymbols  = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	wallSymbols  = "0123456789"
+	otherSymbols = "#$%&*+-=@^~!?/\\|<>()[]{}_:,'`\"" + atomSymbols + wallSymbols
+)
+
+// IsText reports whether a level file name is for a text level.
+func IsText(name string) bool {
+	return strings.EqualFold(filepath.Ext(name), TextExt)
+}
+
+// ReadText reads a level in the text format.
+func ReadText(r io.Reader) (*Level, error) {
+	t := &textReader{
+		s:      bufio.NewScanner(r),
+		legend: map[byte]int{' ': 0, '.': FREE},
+	}
+	l := &Level{Width: LevelSize, Height: LevelSize}
+	if err := t.read(l); err != nil {
+		return nil, fmt.Errorf("line %d: %v", t.line, err)
+	}
+
+	l.Field.Fit()
+	l.Solution.Fit()
+	return l, nil
+}
+
+type textReader struct {
+	s      *bufio.Scanner
+	line   int
+	legend map[byte]int
+}
+
+func (t *textReader) next() (string, bool) {
+	if !t.s.Scan() {
+		return "", false
+	}
+	t.line++
+	return strings.TrimRight(t.s.Text(), "\r"), true
+}
+
+func (t *textReader) read(l *Level) error {
+	desc := 0
+	for {
+		text, ok := t.next()
+		if !ok {
+			return t.s.Err()
+		}
+		key, value := text, ""
+		if n := strings.IndexByte(text, ' '); n >= 0 {
+			key, value = text[:n], strings.TrimSpace(text[n+1:])
+		}
+
+		var err error
+		switch key {
+		case "":
+		case "atomiks":
+			var v int
+			v, err = strconv.Atoi(value)
+			if err == nil && (v < 1 || v > LevelVersion) {
+				err = ErrLevelVersion
+			}
+		case "size":
+			_, err = fmt.Sscan(value, &l.Width, &l.Height)
+			if err == nil && (l.Width < 1 || l.Height < 1 || !l.Field.Inside(l.Width-1, l.Height-1)) {
+				err = ErrLevelFormat
+			}
+		case "time":
+			l.Duration, err = strconv.Atoi(value)
+			if err == nil && (l.Duration < 1 || l.Duration > MaxDuration) {
+				err = ErrLevelFormat
+			}
+		case "desc":
+			if desc >= len(l.Desc) {
+				return ErrLevelFormat
+			}
+			var s string
+			s, err = strconv.Unquote(value)
+			if len(s) > len(l.Desc[desc]) {
+				err = ErrLevelFormat
+			}
+			copy(l.Desc[desc][:], s)
+			desc++
+		case "cursor":
+			l.Cursor, err = strconv.Atoi(value)
+		case "background":
+			l.BG, err = strconv.Atoi(value)
+		case "par":
+			l.Par, err = strconv.Atoi(value)
+		case "match":
+			l.Match, err = parseMatch(value)
+		case "title":
+			l.Title, err = strconv.Unquote(value)
+		case "author":
+			l.Author, err = strconv.Unquote(value)
+		case "molecule":
+			l.Molecule, err = strconv.Unquote(value)
+		case "formula":
+			l.Formula, err = strconv.Unquote(value)
+		case "blurb":
+			l.Blurb, err = strconv.Unquote(value)
+		case "legend":
+			err = t.readLegend()
+		case "field":
+			err = t.readGrid(&l.Field, l.Width, l.Height)
+		case "solution":
+			err = t.readGrid(&l.Solution, l.Width, l.Height)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (t *textReader) readLegend() error {
+	for {
+		text, ok := t.next()
+		if !ok || text == "" {
+			return t.s.Err()
+		}
+
+		var (
+			sym   byte
+			name  string
+			index int
+		)
+		if len(text) < 3 || text[1] != ' ' {
+			return ErrLevelFormat
+		}
+		sym = text[0]
+		if _, err := fmt.Sscan(text[2:], &name, &index); err != nil {
+			return err
+		}
+
+		value := -1
+		for typ, s := range typeNames {
+			if s == name {
+				value = typ
+			}
+		}
+		if value < 0 || index < 0 || index > INDEX {
+			return ErrLevelFormat
+		}
+		t.legend[sym] = value | index
+	}
+}
+
+func (t *textReader) readGrid(g *Grid, width, height int) error {
+	for y := 0; y < height; y++ {
+		text, ok := t.next()
+		if !ok {
+			return ErrShortLevel
+		}
+		if len(text) > width {
+			return ErrLevelFormat
+		}
+		for x := 0; x < len(text); x++ {
+			v, found := t.legend[text[x]]
+			if !found {
+				return fmt.Errorf("unknown square %q", text[x])
+			}
+			g.Set(x, y, v)
+		}
+	}
+	return nil
+}
+
+// WriteText writes the level in the text format.
+func (l *Level) WriteText(wr io.Writer) error {
+	width, height := l.Width, l.Height
+	if width == 0 || height == 0 {
+		width, height = LevelSize, LevelSize
+	}
+
+	symbols, legend, err := l.symbols(width, height)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(wr)
+	fmt.Fprintf(w, "atomiks %d\n", LevelVersion)
+	fmt.Fprintf(w, "size %d %d\n", width, height)
+	fmt.Fprintf(w, "time %d\n", l.Duration)
+	for i := range l.Desc {
+		desc := l.Desc[i][:]
+		for len(desc) > 0 && desc[len(desc)-1] == 0 {
+			desc = desc[:len(desc)-1]
+		}
+		fmt.Fprintf(w, "desc %s\n", strconv.Quote(string(desc)))
+	}
+	fmt.Fprintf(w, "cursor %d\n", l.Cursor)
+	fmt.Fprintf(w, "background %d\n", l.BG)
+	if l.Par > 0 {
+		fmt.Fprintf(w, "par %d\n", l.Par)
+	}
+	if l.Match != 0 {
+		fmt.Fprintf(w, "match %s\n", matchString(l.Match))
+	}
+	if l.Title != "" {
+		fmt.Fprintf(w, "title %s\n", strconv.Quote(l.Title))
+	}
+	if l.Author != "" {
+		fmt.Fprintf(w, "author %s\n", strconv.Quote(l.Author))
+	}
+	if l.Molecule != "" {
+		fmt.Fprintf(w, "molecule %s\n", strconv.Quote(l.Molecule))
+	}
+	if l.Formula != "" {
+		fmt.Fprintf(w, "formula %s\n", strconv.Quote(l.Formula))
+	}
+	if l.Blurb != "" {
+		fmt.Fprintf(w, "blurb %s\n", strconv.Quote(l.Blurb))
+	}
+
+	if len(legend) > 0 {
+		fmt.Fprintf(w, "\nlegend\n")
+		for _, v := range legend {
+			fmt.Fprintf(w, "%c %s %d\n", symbols[v], typeNames[v&TYPE], v&INDEX)
+		}
+	}
+
+	fmt.Fprintf(w, "\nfield\n")
+	writeGrid(w, &l.Field, symbols, width, height)
+	fmt.Fprintf(w, "\nsolution\n")
+	writeGrid(w, &l.Solution, symbols, width, height)
+
+	return w.Flush()
+}
+
+// symbols picks a character for every square used by the level, atoms
+// get letters and walls digits as long as there are enough of them.
+func (l *Level) symbols(width, height int) (map[int]byte, []int, error) {
+	symbols := map[int]byte{0: ' ', FREE: '.'}
+	used := map[byte]bool{' ': true, '.': true}
+	var legend []int
+
+	pick := func(pool string) byte {
+		for i := 0; i < len(pool); i++ {
+			if !used[pool[i]] {
+				return pool[i]
+			}
+		}
+		return 0
+	}
+
+	for _, g := range []*Grid{&l.Field, &l.Solution} {
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				v := g.At(x, y)
+				if _, found := symbols[v]; found {
+					continue
+				}
+
+				var sym byte
+				switch v & TYPE {
+				case ATOM:
+					sym = pick(atomSymbols)
+				case WALL:
+					sym = pick(wallSymbols)
+				}
+				if sym == 0 {
+					sym = pick(otherSymbols)
+				}
+				if sym == 0 {
+					return nil, nil, ErrTextSymbols
+				}
+
+				symbols[v] = sym
+				used[sym] = true
+				legend = append(legend, v)
+			}
+		}
+	}
+	return symbols, legend, nil
+}
+
+func writeGrid(w *bufio.Writer, g *Grid, symbols map[int]byte, width, height int) {
+	for y := 0; y < height; y++ {
+		row := make([]byte, width)
+		for x := range row {
+			row[x] = symbols[g.At(x, y)]
+		}
+		w.Write(bytes.TrimRight(row, " "))
+		w.WriteByte('\n')
+	}
+}
+
+var matchNames = []string{"shape", "turned"}
+
+// parseMatch reads the ways a level matches the solution given as words,
+// like "shape turned".
+func parseMatch(value string) (int, error) {
+	m := 0
+next:
+	for _, word := range strings.Fields(value) {
+		for i, name := range matchNames {
+			if word == name {
+				m |= 1 << uint(i)
+				continue next
+			}
+		}
+		return 0, fmt.Errorf("unknown match %q", word)
+	}
+	return m, nil
+}
+
+func matchString(m int) string {
+	var words []string
+	for i, name := range matchNames {
+		if m&(1<<uint(i)) != 0 {
+			words = append(words, name)
+		}
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,45 @@
+package puzzle
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadTextTime(t *testing.T) {
+	l, err := LoadLevel(filepath.Join("..", "assets", "lev", "lev0001.dat"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := new(bytes.Buffer)
+	if err := l.WriteText(w); err != nil {
+		t.Fatal(err)
+	}
+	text := w.String()
+	line := fmt.Sprintf("time %d\n", l.Duration)
+	if !strings.Contains(text, line) {
+		t.Fatalf("no %q in the text level", line)
+	}
+
+	tests := []struct {
+		time string
+		ok   bool
+	}{
+		{"1", true},
+		{"120", true},
+		{"599", true},
+		{"600", false},
+		{"660", false},
+		{"0", false},
+		{"-5", false},
+	}
+	for _, tt := range tests {
+		src := strings.Replace(text, line, "time "+tt.time+"\n", 1)
+		_, err := ReadText(strings.NewReader(src))
+		if ok := err == nil; ok != tt.ok {
+			t.Errorf("time %s: error %v", tt.time, err)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/qeedquan/go-atomiks/puzzle"
+)
+
+var (
+	assets = flag.String("assets", "assets", "assets directory")
+	limit  = flag.Int("limit", 300000, "maximum number of states to search")
+	build  = flag.Int("build", 2000000, "maximum number of states to spend building the molecule an atom at a time once the search gives up, 0 to never")
+	weight = flag.Int("w", 1, "weight of the move estimate, above 1 gives faster but longer solutions")
+	quiet  = flag.Bool("q", false, "only print the number of moves")
+)
+
+var dirs = [...]string{
+	puzzle.UP:    "UP",
+	puzzle.RIGHT: "RIGHT",
+	puzzle.DOWN:  "DOWN",
+	puzzle.LEFT:  "LEFT",
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	files := flag.Args()
+	if len(files) == 0 {
+		for _, pat := range []string{"lev*.dat", "lev*" + puzzle.TextExt} {
+			m, err := filepath.Glob(filepath.Join(*assets, "lev", pat))
+			ck(err)
+			files = append(files, m...)
+		}
+		sort.Strings(files)
+	}
+
+	status := 0
+	for _, name := range files {
+		if !solve(name) {
+			status = 1
+		}
+	}
+	os.Exit(status)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: solver [options] [level ...]")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func solve(name string) bool {
+	l, err := puzzle.LoadLevel(name)
+	if ek(err) {
+		return false
+	}
+
+	var p puzzle.Puzzle
+	p.Init(l)
+	s := puzzle.Solver{Limit: *limit, Weight: *weight, Build: *build}
+	moves, shortest, err := s.Solve(&p)
+	if err != nil {
+		fmt.Printf("%s: %v\n", name, err)
+		return false
+	}
+
+	if shortest {
+		fmt.Printf("%s: %d moves\n", name, len(moves))
+	} else {
+		fmt.Printf("%s: %d moves (not shortest)\n", name, len(moves))
+	}
+	if *quiet {
+		return true
+	}
+	for i, m := range moves {
+		fmt.Printf("\t%2d: (%d,%d) %s -> (%d,%d)\n", i+1, m.From.X, m.From.Y, dirs[m.Dir], m.To.X, m.To.Y)
+	}
+	return true
+}
+
+func ck(err error) {
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "solver:", err)
+		os.Exit(1)
+	}
+}
+
+func ek(err error) bool {
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "solver:", err)
+		return true
+	}
+	return false
+}